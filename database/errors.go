@@ -0,0 +1,50 @@
+package database
+
+import (
+	"afperdomo2/go/rest-ws/repository"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Códigos de error de PostgreSQL que se traducen a errores de dominio
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+	pgInvalidText         = "22P02"
+)
+
+// translateError convierte los errores de PostgreSQL en errores de dominio del repositorio
+// Los errores que no se reconocen se devuelven sin cambios
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case pgUniqueViolation:
+		if pqErr.Constraint == "users_email_key" {
+			return repository.ErrEmailTaken
+		}
+		return &repository.Error{Kind: repository.ErrConflict, Message: "resource already exists", Err: err}
+	case pgForeignKeyViolation:
+		// Borrar un registro referenciado es un conflicto; referenciar uno inexistente es un dato inválido
+		if strings.HasPrefix(pqErr.Message, "update or delete") {
+			return &repository.Error{Kind: repository.ErrConflict, Message: "resource is still referenced by other resources", Err: err}
+		}
+		return &repository.Error{Kind: repository.ErrValidation, Message: "referenced resource does not exist", Err: err}
+	case pgNotNullViolation, pgCheckViolation, pgStringTooLong, pgInvalidText:
+		message := "invalid value"
+		if pqErr.Column != "" {
+			message = "invalid value for " + pqErr.Column
+		}
+		return &repository.Error{Kind: repository.ErrValidation, Message: message, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,398 @@
+package database
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository es una implementación en memoria de repository.Repository
+// Pensada para pruebas y ejecuciones locales sin una base de datos PostgreSQL
+// Es segura para uso concurrente y replica el comportamiento de PostgresRepository
+type MemoryRepository struct {
+	mutex         sync.RWMutex
+	users         map[int64]*models.User
+	posts         map[int64]*models.Post
+	refreshTokens map[int64]*models.RefreshToken
+	revokedTokens map[string]time.Time // jti -> expiración del token revocado
+	userTokens    map[int64]*models.UserToken
+	lastUserId    int64 // Simula la secuencia SERIAL de la tabla users
+	lastPostId    int64 // Simula la secuencia SERIAL de la tabla posts
+	lastTokenId   int64 // Simula la secuencia SERIAL de la tabla refresh_tokens
+	lastUserToken int64 // Simula la secuencia SERIAL de la tabla user_tokens
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		users:         make(map[int64]*models.User),
+		posts:         make(map[int64]*models.Post),
+		refreshTokens: make(map[int64]*models.RefreshToken),
+		revokedTokens: make(map[string]time.Time),
+		userTokens:    make(map[int64]*models.UserToken),
+	}
+}
+
+func (r *MemoryRepository) Ping(ctx context.Context) error {
+	return nil // Siempre disponible mientras el proceso esté vivo
+}
+
+func (r *MemoryRepository) Close() error {
+	return nil // No hay conexiones que cerrar
+}
+
+func (r *MemoryRepository) CreateUser(ctx context.Context, user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Respeta la restricción UNIQUE de la columna email
+	for _, u := range r.users {
+		if u.Email == user.Email {
+			return repository.ErrEmailTaken
+		}
+	}
+
+	r.lastUserId++
+	stored := *user
+	stored.Id = r.lastUserId
+	if stored.Role == "" {
+		stored.Role = models.RoleUser
+	}
+	r.users[stored.Id] = &stored
+	user.Id = stored.Id
+	return nil
+}
+
+func (r *MemoryRepository) GetUserById(ctx context.Context, id int64) (*models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	// Igual que en PostgreSQL, la contraseña no se incluye al buscar por ID
+	user := *stored
+	user.Password = ""
+	return &user, nil
+}
+
+func (r *MemoryRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, stored := range r.users {
+		if stored.Email == email {
+			user := *stored
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (r *MemoryRepository) ListUsers(ctx context.Context, page int64, limit int64) ([]*models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ids := make([]int64, 0, len(r.users))
+	for id := range r.users {
+		ids = append(ids, id)
+	}
+	// Mismo orden que la consulta SQL: ORDER BY id
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	offset := (page - 1) * limit
+	var users []*models.User
+	for i := offset; i >= 0 && i < int64(len(ids)) && i < offset+limit; i++ {
+		user := *r.users[ids[i]]
+		user.Password = ""
+		users = append(users, &user)
+	}
+	return users, nil
+}
+
+func (r *MemoryRepository) UpdateUserRole(ctx context.Context, id int64, role string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Respeta la restricción CHECK de la columna role
+	if !models.IsValidRole(role) {
+		return repository.Validation("invalid value")
+	}
+	stored, ok := r.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	stored.Role = role
+	return nil
+}
+
+func (r *MemoryRepository) UpdateUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	now := time.Now()
+	stored.Password = passwordHash
+	stored.PasswordChangedAt = &now
+	return nil
+}
+
+func (r *MemoryRepository) MarkUserVerified(ctx context.Context, id int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	// Igual que en PostgreSQL, se conserva la fecha de la primera verificación
+	if stored.VerifiedAt == nil {
+		now := time.Now()
+		stored.VerifiedAt = &now
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteUser(ctx context.Context, id int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return repository.ErrUserNotFound
+	}
+	// Respeta el ON DELETE RESTRICT de posts.user_id
+	for _, post := range r.posts {
+		if post.UserID == id {
+			return repository.Conflict("resource is still referenced by other resources")
+		}
+	}
+	// Y el ON DELETE CASCADE de refresh_tokens.user_id y user_tokens.user_id
+	for tokenId, token := range r.refreshTokens {
+		if token.UserId == id {
+			delete(r.refreshTokens, tokenId)
+		}
+	}
+	for tokenId, token := range r.userTokens {
+		if token.UserId == id {
+			delete(r.userTokens, tokenId)
+		}
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *MemoryRepository) CreatePost(ctx context.Context, post *models.Post) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Respeta la llave foránea posts.user_id -> users.id
+	if _, ok := r.users[post.UserID]; !ok {
+		return repository.Validation("referenced resource does not exist")
+	}
+
+	r.lastPostId++
+	post.Id = r.lastPostId
+	post.CreatedAt = time.Now()
+	stored := *post
+	r.posts[stored.Id] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) GetPostById(ctx context.Context, id int64) (*models.Post, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	stored, ok := r.posts[id]
+	if !ok {
+		return nil, repository.ErrPostNotFound
+	}
+	post := *stored
+	return &post, nil
+}
+
+func (r *MemoryRepository) UpdatePost(ctx context.Context, id int64, changes *models.Post, userId int64, anyOwner bool) (*models.Post, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, err := r.ownedPost(id, userId, anyOwner)
+	if err != nil {
+		return nil, err
+	}
+	stored.Title = changes.Title
+	stored.Content = changes.Content
+	post := *stored
+	return &post, nil
+}
+
+func (r *MemoryRepository) DeletePost(ctx context.Context, id int64, userId int64, anyOwner bool) (*models.Post, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, err := r.ownedPost(id, userId, anyOwner)
+	if err != nil {
+		return nil, err
+	}
+	delete(r.posts, id)
+	return stored, nil
+}
+
+// ownedPost devuelve el post si userId puede modificarlo, como writeOwnedPost de PostgresRepository
+// Debe llamarse con el mutex tomado
+func (r *MemoryRepository) ownedPost(id int64, userId int64, anyOwner bool) (*models.Post, error) {
+	stored, ok := r.posts[id]
+	if !ok {
+		return nil, repository.ErrPostNotFound
+	}
+	if stored.UserID != userId && !anyOwner {
+		return nil, repository.ErrNotPostOwner
+	}
+	return stored, nil
+}
+
+func (r *MemoryRepository) GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ids := make([]int64, 0, len(r.posts))
+	for id := range r.posts {
+		ids = append(ids, id)
+	}
+	// Mismo orden que la consulta SQL: ORDER BY id DESC
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	offset := (page - 1) * limit
+	var posts []*models.Post
+	for i := offset; i >= 0 && i < int64(len(ids)) && i < offset+limit; i++ {
+		post := *r.posts[ids[i]]
+		posts = append(posts, &post)
+	}
+	return posts, nil
+}
+
+func (r *MemoryRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.users[token.UserId]; !ok {
+		return repository.Validation("referenced resource does not exist")
+	}
+
+	r.lastTokenId++
+	token.Id = r.lastTokenId
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.refreshTokens[stored.Id] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, stored := range r.refreshTokens {
+		if stored.TokenHash == tokenHash {
+			token := *stored
+			return &token, nil
+		}
+	}
+	return nil, repository.ErrRefreshTokenNotFound
+}
+
+func (r *MemoryRepository) RevokeRefreshToken(ctx context.Context, id int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.refreshTokens[id]
+	if !ok || stored.RevokedAt != nil {
+		return repository.ErrRefreshTokenRevoked
+	}
+	now := time.Now()
+	stored.RevokedAt = &now
+	return nil
+}
+
+func (r *MemoryRepository) RevokeUserRefreshTokens(ctx context.Context, userId int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, stored := range r.refreshTokens {
+		if stored.UserId == userId && stored.RevokedAt == nil {
+			stored.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Limpia los registros cuyo token ya expiró: no hace falta recordarlos
+	now := time.Now()
+	for id, exp := range r.revokedTokens {
+		if exp.Before(now) {
+			delete(r.revokedTokens, id)
+		}
+	}
+	r.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (r *MemoryRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	_, revoked := r.revokedTokens[jti]
+	return revoked, nil
+}
+
+func (r *MemoryRepository) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Respeta la llave foránea user_tokens.user_id -> users.id
+	if _, ok := r.users[token.UserId]; !ok {
+		return repository.Validation("referenced resource does not exist")
+	}
+
+	r.lastUserToken++
+	token.Id = r.lastUserToken
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.userTokens[stored.Id] = &stored
+	return nil
+}
+
+func (r *MemoryRepository) ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (*models.UserToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, stored := range r.userTokens {
+		if stored.TokenHash == tokenHash && stored.Purpose == purpose && stored.IsActive(now) {
+			stored.UsedAt = &now
+			token := *stored
+			return &token, nil
+		}
+	}
+	return nil, repository.ErrUserTokenNotFound
+}
+
+func (r *MemoryRepository) DeleteUserTokens(ctx context.Context, userId int64, purpose string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for tokenId, token := range r.userTokens {
+		if token.UserId == userId && token.Purpose == purpose {
+			delete(r.userTokens, tokenId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMemoryRepositoryUsers(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	user := &models.User{Email: "ana@x.io", Password: "hash"}
+	if err := r.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.Id != 1 {
+		t.Fatalf("user.Id = %d, want 1", user.Id)
+	}
+	if err := r.CreateUser(ctx, &models.User{Email: "ana@x.io", Password: "hash"}); !errors.Is(err, repository.ErrEmailTaken) {
+		t.Fatalf("CreateUser with a taken email = %v, want ErrEmailTaken", err)
+	}
+
+	// Como en PostgreSQL: rol por defecto y sin contraseña al buscar por ID
+	byId, err := r.GetUserById(ctx, user.Id)
+	if err != nil || byId.Role != models.RoleUser || byId.Password != "" {
+		t.Fatalf("GetUserById = %+v (%v), want role %q without password", byId, err, models.RoleUser)
+	}
+	if byEmail, err := r.GetUserByEmail(ctx, "ana@x.io"); err != nil || byEmail.Password != "hash" {
+		t.Fatalf("GetUserByEmail = %+v (%v), want the password hash", byEmail, err)
+	}
+	if _, err := r.GetUserById(ctx, 99); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetUserById(99) = %v, want ErrNotFound", err)
+	}
+
+	if err := r.UpdateUserRole(ctx, user.Id, "root"); !errors.Is(err, repository.ErrValidation) {
+		t.Fatalf("UpdateUserRole with an invalid role = %v, want ErrValidation", err)
+	}
+
+	// ON DELETE RESTRICT: un usuario con posts no se puede borrar
+	r.CreatePost(ctx, &models.Post{Title: "Hola", Content: "x", UserID: user.Id})
+	if err := r.DeleteUser(ctx, user.Id); !errors.Is(err, repository.ErrConflict) {
+		t.Fatalf("DeleteUser with posts = %v, want ErrConflict", err)
+	}
+}
+
+func TestMemoryRepositoryPosts(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	owner := &models.User{Email: "ana@x.io"}
+	other := &models.User{Email: "bob@x.io"}
+	r.CreateUser(ctx, owner)
+	r.CreateUser(ctx, other)
+
+	if err := r.CreatePost(ctx, &models.Post{Title: "x", Content: "x", UserID: 99}); !errors.Is(err, repository.ErrValidation) {
+		t.Fatalf("CreatePost for a missing user = %v, want ErrValidation", err)
+	}
+
+	post := &models.Post{Title: "Hola", Content: "Primer post", UserID: owner.Id}
+	if err := r.CreatePost(ctx, post); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	if post.Id == 0 || post.CreatedAt.IsZero() {
+		t.Fatalf("CreatePost did not fill id and created_at: %+v", post)
+	}
+	stored, err := r.GetPostById(ctx, post.Id)
+	if err != nil || *stored != *post {
+		t.Fatalf("GetPostById = %+v (%v), want %+v", stored, err, post)
+	}
+
+	changes := &models.Post{Title: "Editado", Content: "Nuevo"}
+	if _, err := r.UpdatePost(ctx, post.Id, changes, other.Id, false); !errors.Is(err, repository.ErrNotPostOwner) {
+		t.Fatalf("UpdatePost by another user = %v, want ErrNotPostOwner", err)
+	}
+	if _, err := r.UpdatePost(ctx, 99, changes, owner.Id, true); !errors.Is(err, repository.ErrPostNotFound) {
+		t.Fatalf("UpdatePost of a missing post = %v, want ErrPostNotFound", err)
+	}
+	updated, err := r.UpdatePost(ctx, post.Id, changes, other.Id, true)
+	if err != nil || updated.Title != "Editado" || updated.Content != "Nuevo" || updated.UserID != owner.Id {
+		t.Fatalf("UpdatePost with anyOwner = %+v (%v)", updated, err)
+	}
+
+	// El post devuelto es una copia: modificarlo no cambia el almacenado
+	updated.Title = "Cambiado fuera"
+	if stored, _ := r.GetPostById(ctx, post.Id); stored.Title != "Editado" {
+		t.Fatalf("stored title = %q, want %q", stored.Title, "Editado")
+	}
+
+	if _, err := r.DeletePost(ctx, post.Id, other.Id, false); !errors.Is(err, repository.ErrNotPostOwner) {
+		t.Fatalf("DeletePost by another user = %v, want ErrNotPostOwner", err)
+	}
+	if deleted, err := r.DeletePost(ctx, post.Id, owner.Id, false); err != nil || deleted.Id != post.Id {
+		t.Fatalf("DeletePost by the owner = %+v (%v)", deleted, err)
+	}
+	if _, err := r.GetPostById(ctx, post.Id); !errors.Is(err, repository.ErrPostNotFound) {
+		t.Fatalf("GetPostById after delete = %v, want ErrPostNotFound", err)
+	}
+	if _, err := r.DeletePost(ctx, post.Id, owner.Id, false); !errors.Is(err, repository.ErrPostNotFound) {
+		t.Fatalf("DeletePost twice = %v, want ErrPostNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryPostPagination(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	user := &models.User{Email: "ana@x.io"}
+	r.CreateUser(ctx, user)
+	for range 5 {
+		r.CreatePost(ctx, &models.Post{Title: "x", Content: "x", UserID: user.Id})
+	}
+
+	// Mismo orden que la consulta SQL: ORDER BY id DESC LIMIT limit OFFSET (page - 1) * limit
+	cases := []struct {
+		page, limit int64
+		want        []int64
+	}{
+		{1, 2, []int64{5, 4}},
+		{2, 2, []int64{3, 2}},
+		{3, 2, []int64{1}},
+		{4, 2, nil},
+		{1, 10, []int64{5, 4, 3, 2, 1}},
+	}
+	for _, tc := range cases {
+		posts, err := r.GetAllPosts(ctx, tc.page, tc.limit)
+		if err != nil {
+			t.Fatalf("GetAllPosts(%d, %d): %v", tc.page, tc.limit, err)
+		}
+		var ids []int64
+		for _, post := range posts {
+			ids = append(ids, post.Id)
+		}
+		if !slices.Equal(ids, tc.want) {
+			t.Errorf("GetAllPosts(%d, %d) ids = %v, want %v", tc.page, tc.limit, ids, tc.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"afperdomo2/go/rest-ws/logging"
+	"afperdomo2/go/rest-ws/metrics"
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// instrumentedDB envuelve *sql.DB y registra la latencia y los errores de cada consulta (métricas y log)
+// La etiqueta de cada consulta es su sentencia y su tabla (ej: "SELECT users"), deducidas del SQL,
+// de modo que el número de series está acotado por las consultas del código
+type instrumentedDB struct {
+	*sql.DB
+}
+
+func (db *instrumentedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	observe(ctx, query, start, err)
+	return result, err
+}
+
+func (db *instrumentedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	observe(ctx, query, start, err)
+	return rows, err
+}
+
+// QueryRowContext registra el error de la consulta; sql.ErrNoRows solo aparece en Scan y no cuenta como error
+func (db *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	observe(ctx, query, start, row.Err())
+	return row
+}
+
+// observe registra la métrica de la consulta y una línea de log (debug; warn si falló)
+// El log usa ctx, de modo que incluye el request ID de la request que originó la consulta
+func observe(ctx context.Context, query string, start time.Time, err error) {
+	label := queryLabel(query)
+	duration := time.Since(start)
+	metrics.ObserveDBQuery(label, duration, err)
+	if err != nil {
+		slog.WarnContext(ctx, "database query failed", "query", label, "duration_ms", logging.Milliseconds(duration), "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "database query", "query", label, "duration_ms", logging.Milliseconds(duration))
+}
+
+// queryLabel resume una consulta como "<SENTENCIA> <tabla>"
+// Ej: "SELECT id FROM users WHERE id = $1" -> "SELECT users"
+func queryLabel(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	statement := strings.ToUpper(fields[0])
+
+	// Palabra tras la que aparece la tabla en cada sentencia
+	var keyword string
+	switch statement {
+	case "SELECT", "DELETE":
+		keyword = "FROM"
+	case "INSERT":
+		keyword = "INTO"
+	case "UPDATE":
+		return statement + " " + tableName(fields, 1)
+	default:
+		return statement
+	}
+	for i, field := range fields {
+		if strings.EqualFold(field, keyword) {
+			return statement + " " + tableName(fields, i+1)
+		}
+	}
+	return statement
+}
+
+// tableName devuelve el nombre de tabla en fields[i], sin paréntesis ni comas
+func tableName(fields []string, i int) string {
+	if i >= len(fields) {
+		return "unknown"
+	}
+	return strings.ToLower(strings.TrimRight(fields[i], "(,;"))
+}
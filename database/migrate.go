@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationsFS contiene las migraciones SQL, embebidas en el binario
+// Cada migración son dos archivos: <versión>_<nombre>.up.sql y <versión>_<nombre>.down.sql
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockId identifica el advisory lock de PostgreSQL que serializa las migraciones,
+// para que varias instancias arrancando a la vez no apliquen la misma migración
+const migrationLockId = 727_100_016
+
+var ErrNoMigrationsApplied = errors.New("no migrations applied")
+
+// Migration es un cambio versionado del esquema
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string // SQL para aplicar la migración
+	Down    string // SQL para revertirla
+}
+
+// MigrationStatus indica si una migración está aplicada y cuándo se aplicó
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time // nil si la migración está pendiente
+}
+
+// Migrator aplica y revierte las migraciones embebidas sobre una base de datos PostgreSQL
+// Las migraciones aplicadas se registran en la tabla schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator crea un Migrator con las migraciones embebidas, ordenadas por versión
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations lee los pares up/down de un directorio y los ordena por versión
+// Falla si un archivo no sigue la convención de nombres, si una versión se repite
+// o si a una migración le falta su archivo up o down
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, found := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.%s.sql", fileName, direction)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up aplica, en orden, todas las migraciones pendientes
+// Cada migración se aplica en su propia transacción junto con su registro en schema_migrations,
+// de modo que una migración fallida no deja el esquema a medias
+// Retorna las migraciones aplicadas (vacío si el esquema ya estaba al día)
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down revierte las últimas steps migraciones aplicadas, de la más reciente a la más antigua
+// Retorna ErrNoMigrationsApplied si no hay nada que revertir
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		steps = 1
+	}
+
+	var reverted []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		if len(reverted) == 0 {
+			return ErrNoMigrationsApplied
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status devuelve todas las migraciones conocidas, indicando cuáles están aplicadas
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			entry := MigrationStatus{Migration: migration}
+			if appliedAt, ok := done[migration.Version]; ok {
+				entry.AppliedAt = &appliedAt
+			}
+			status = append(status, entry)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// withLock ejecuta fn sobre una única conexión que mantiene el advisory lock de migraciones
+// y se asegura de que exista la tabla schema_migrations
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockId); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockId)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS
+    schema_migrations (
+        version BIGINT PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    )`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// appliedVersions devuelve las versiones registradas en schema_migrations y su fecha de aplicación
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// inTx ejecuta fn dentro de una transacción: confirma si fn termina bien y revierte si falla
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// migrationFS crea un directorio "migrations" con los archivos indicados; cada uno contiene "-- <nombre>"
+func migrationFS(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := migrationFS(
+		"0010_add_index.up.sql", "0010_add_index.down.sql",
+		"0002_create_posts.down.sql", "0002_create_posts.up.sql",
+		"0001_create_users.up.sql", "0001_create_users.down.sql",
+	)
+
+	migrations, err := loadMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+
+	// Ordenadas por versión numérica (10 después de 2), con el SQL de cada dirección
+	want := []struct {
+		version int64
+		name    string
+	}{{1, "create_users"}, {2, "create_posts"}, {10, "add_index"}}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d", len(migrations), len(want))
+	}
+	for i, migration := range migrations {
+		if migration.Version != want[i].version || migration.Name != want[i].name {
+			t.Errorf("migration %d = %d_%s, want %d_%s", i, migration.Version, migration.Name, want[i].version, want[i].name)
+		}
+		file := fmt.Sprintf("-- %04d_%s", migration.Version, migration.Name)
+		if migration.Up != file+".up.sql" || migration.Down != file+".down.sql" {
+			t.Errorf("migration %d_%s: up %q, down %q", migration.Version, migration.Name, migration.Up, migration.Down)
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{"bad suffix", migrationFS("0001_init.sql"), "expected .up.sql or .down.sql suffix"},
+		{"non-numeric version", migrationFS("v1_init.up.sql", "v1_init.down.sql"), "expected <version>_<name>"},
+		{"zero version", migrationFS("0000_init.up.sql", "0000_init.down.sql"), "expected <version>_<name>"},
+		{"missing name", migrationFS("0001.up.sql", "0001.down.sql"), "expected <version>_<name>"},
+		{"conflicting names", migrationFS("0001_init.up.sql", "0001_other.down.sql"), `conflicting names "init" and "other"`},
+		{"missing down", migrationFS("0001_init.up.sql"), "both up and down files are required"},
+		{"missing up", migrationFS("0001_init.down.sql"), "both up and down files are required"},
+		{"missing directory", fstest.MapFS{}, "migrations"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadMigrations(tc.fsys, "migrations")
+			if err == nil || !strings.Contains(err.Error(), tc.error) {
+				t.Fatalf("loadMigrations = %v, want an error containing %q", err, tc.error)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		t.Fatalf("embedded migrations: %v", err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %d has version %d: versions must be consecutive", i, migration.Version)
+		}
+	}
+}
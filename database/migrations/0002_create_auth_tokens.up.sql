@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS
+    refresh_tokens (
+        id SERIAL PRIMARY KEY,
+        user_id INT NOT NULL,
+        token_hash VARCHAR(64) NOT NULL UNIQUE,
+        expires_at TIMESTAMP NOT NULL,
+        revoked_at TIMESTAMP NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON UPDATE CASCADE ON DELETE CASCADE
+    );
+
+CREATE TABLE IF NOT EXISTS
+    revoked_tokens (
+        jti VARCHAR(64) PRIMARY KEY,
+        expires_at TIMESTAMP NOT NULL
+    );
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+
+DROP TABLE IF EXISTS user_tokens;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS
+    user_tokens (
+        id SERIAL PRIMARY KEY,
+        user_id INT NOT NULL,
+        purpose VARCHAR(32) NOT NULL,
+        token_hash VARCHAR(64) NOT NULL UNIQUE,
+        expires_at TIMESTAMP NOT NULL,
+        used_at TIMESTAMP NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        FOREIGN KEY (user_id) REFERENCES users (id) ON UPDATE CASCADE ON DELETE CASCADE
+    );
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_purpose_idx ON user_tokens (user_id, purpose);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP NULL;
+
+-- Las cuentas existentes se crearon antes de exigir la verificación: se consideran verificadas
+UPDATE users SET verified_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE verified_at IS NULL;
@@ -1,121 +1,345 @@
-package database
-
-import (
-	"afperdomo2/go/rest-ws/models"
-	"context"
-	"database/sql"
-	"errors"
-
-	_ "github.com/lib/pq" // Importa el driver de PostgreSQL
-)
-
-type PostgresRepository struct {
-	db *sql.DB
-}
-
-func NewPostgresRepository(url string) (*PostgresRepository, error) {
-	db, err := sql.Open("postgres", url)
-	if err != nil {
-		return nil, err
-	}
-	// Verificar que la conexión sea válida realizando un ping
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, err
-	}
-	return &PostgresRepository{db: db}, nil
-}
-
-func (r *PostgresRepository) Close() error {
-	// Cierra la conexión a la base de datos
-	if r.db != nil {
-		return r.db.Close()
-	}
-	return nil // Si no hay conexión, retorna nil
-}
-
-func (r *PostgresRepository) CreateUser(ctx context.Context, user *models.User) error {
-	// Utiliza un contexto para manejar la operación de forma segura
-	// Realiza una inserción en la base de datos para crear un nuevo usuario
-	_, err := r.db.ExecContext(ctx, "INSERT INTO users (email, password) VALUES ($1, $2)", user.Email, user.Password)
-	return err
-}
-
-func (r *PostgresRepository) GetUserById(ctx context.Context, id int64) (*models.User, error) {
-	// Realiza una consulta a la base de datos para encontrar un usuario por su ID
-	// Utiliza un contexto para manejar la operación de forma segura
-	row := r.db.QueryRowContext(ctx, "SELECT id, email FROM users WHERE id = $1", id)
-
-	var user models.User
-	// Escanea los resultados de la consulta en la estructura del usuario
-	if err := row.Scan(&user.Id, &user.Email); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, err // Error al escanear los resultados
-	}
-	return &user, nil
-}
-
-func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT id, email, password FROM users WHERE email = $1", email)
-
-	var user models.User
-	if err := row.Scan(&user.Id, &user.Email, &user.Password); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
-func (r *PostgresRepository) CreatePost(ctx context.Context, post *models.Post) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO posts (title, content, user_id) VALUES ($1, $2, $3)", post.Title, post.Content, post.UserID)
-	return err
-}
-
-func (r *PostgresRepository) GetPostById(ctx context.Context, id int64) (*models.Post, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT id, title, content, user_id FROM posts WHERE id = $1", id)
-
-	var post models.Post
-	if err := row.Scan(&post.Id, &post.Title, &post.Content, &post.UserID); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("post not found")
-		}
-		return nil, err
-	}
-	return &post, nil
-}
-
-func (r *PostgresRepository) UpdatePost(ctx context.Context, id int64, changes *models.Post) error {
-	_, err := r.db.ExecContext(ctx, "UPDATE posts SET title = $1, content = $2 WHERE id = $3", changes.Title, changes.Content, id)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *PostgresRepository) DeletePost(ctx context.Context, id int64, userId int64) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM posts WHERE id = $1 AND user_id = $2", id, userId)
-	return err
-}
-
-func (r *PostgresRepository) GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error) {
-	offset := (page - 1) * limit
-	rows, err := r.db.QueryContext(ctx, "SELECT id, title, content, user_id, created_at FROM posts ORDER BY id DESC LIMIT $1 OFFSET $2", limit, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var posts []*models.Post
-	for rows.Next() {
-		var post models.Post
-		if err := rows.Scan(&post.Id, &post.Title, &post.Content, &post.UserID, &post.CreatedAt); err != nil {
-			return nil, err
-		}
-		posts = append(posts, &post)
-	}
-	return posts, nil
-}
+package database
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/lib/pq" // Importa el driver de PostgreSQL
+)
+
+type PostgresRepository struct {
+	db *instrumentedDB // Registra la latencia y los errores de cada consulta
+}
+
+func NewPostgresRepository(url string) (*PostgresRepository, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	// Verificar que la conexión sea válida realizando un ping
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PostgresRepository{db: &instrumentedDB{DB: db}}, nil
+}
+
+// Migrator devuelve el Migrator de las migraciones embebidas sobre esta conexión
+func (r *PostgresRepository) Migrator() (*Migrator, error) {
+	return NewMigrator(r.db.DB)
+}
+
+// DB devuelve el pool de conexiones, para publicar sus estadísticas
+func (r *PostgresRepository) DB() *sql.DB {
+	return r.db.DB
+}
+
+func (r *PostgresRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *PostgresRepository) Close() error {
+	// Cierra la conexión a la base de datos
+	if r.db != nil {
+		return r.db.Close()
+	}
+	return nil // Si no hay conexión, retorna nil
+}
+
+func (r *PostgresRepository) CreateUser(ctx context.Context, user *models.User) error {
+	// Utiliza un contexto para manejar la operación de forma segura
+	// Realiza una inserción en la base de datos para crear un nuevo usuario
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	row := r.db.QueryRowContext(ctx, "INSERT INTO users (email, password, role) VALUES ($1, $2, $3) RETURNING id", user.Email, user.Password, role)
+	return translateError(row.Scan(&user.Id))
+}
+
+func (r *PostgresRepository) GetUserById(ctx context.Context, id int64) (*models.User, error) {
+	// Realiza una consulta a la base de datos para encontrar un usuario por su ID
+	// Utiliza un contexto para manejar la operación de forma segura
+	row := r.db.QueryRowContext(ctx, "SELECT id, email, role, password_changed_at, verified_at FROM users WHERE id = $1", id)
+
+	var user models.User
+	// Escanea los resultados de la consulta en la estructura del usuario
+	if err := row.Scan(&user.Id, &user.Email, &user.Role, &user.PasswordChangedAt, &user.VerifiedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrUserNotFound
+		}
+		return nil, err // Error al escanear los resultados
+	}
+	return &user, nil
+}
+
+func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT id, email, password, role, password_changed_at, verified_at FROM users WHERE email = $1", email)
+
+	var user models.User
+	if err := row.Scan(&user.Id, &user.Email, &user.Password, &user.Role, &user.PasswordChangedAt, &user.VerifiedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *PostgresRepository) ListUsers(ctx context.Context, page int64, limit int64) ([]*models.User, error) {
+	offset := (page - 1) * limit
+	rows, err := r.db.QueryContext(ctx, "SELECT id, email, role, verified_at FROM users ORDER BY id LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.Id, &user.Email, &user.Role, &user.VerifiedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	return users, rows.Err()
+}
+
+func (r *PostgresRepository) UpdateUserRole(ctx context.Context, id int64, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, id)
+	if err != nil {
+		return translateError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) UpdateUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1, password_changed_at = $2 WHERE id = $3", passwordHash, time.Now().UTC(), id)
+	if err != nil {
+		return translateError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) MarkUserVerified(ctx context.Context, id int64) error {
+	// COALESCE conserva la fecha de la primera verificación
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET verified_at = COALESCE(verified_at, $1) WHERE id = $2", time.Now().UTC(), id)
+	if err != nil {
+		return translateError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteUser(ctx context.Context, id int64) error {
+	// Los posts del usuario lo referencian con ON DELETE RESTRICT: en ese caso se responde un conflicto
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return translateError(err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreatePost(ctx context.Context, post *models.Post) error {
+	// RETURNING completa el post con los valores generados por la base de datos
+	row := r.db.QueryRowContext(ctx, "INSERT INTO posts (title, content, user_id) VALUES ($1, $2, $3) RETURNING id, created_at", post.Title, post.Content, post.UserID)
+	return translateError(row.Scan(&post.Id, &post.CreatedAt))
+}
+
+func (r *PostgresRepository) GetPostById(ctx context.Context, id int64) (*models.Post, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT id, title, content, user_id, created_at FROM posts WHERE id = $1", id)
+
+	var post models.Post
+	if err := row.Scan(&post.Id, &post.Title, &post.Content, &post.UserID, &post.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrPostNotFound
+		}
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *PostgresRepository) UpdatePost(ctx context.Context, id int64, changes *models.Post, userId int64, anyOwner bool) (*models.Post, error) {
+	return r.writeOwnedPost(ctx, id, userId, anyOwner, `UPDATE posts SET title = $1, content = $2
+		WHERE id = $3 AND (user_id = $4 OR $5)
+		RETURNING id, title, content, user_id, created_at`, changes.Title, changes.Content, id, userId, anyOwner)
+}
+
+func (r *PostgresRepository) DeletePost(ctx context.Context, id int64, userId int64, anyOwner bool) (*models.Post, error) {
+	return r.writeOwnedPost(ctx, id, userId, anyOwner, `DELETE FROM posts
+		WHERE id = $1 AND (user_id = $2 OR $3)
+		RETURNING id, title, content, user_id, created_at`, id, userId, anyOwner)
+}
+
+// writeOwnedPost ejecuta la escritura (UPDATE o DELETE ... RETURNING) de un post en una transacción
+// Antes bloquea la fila con SELECT ... FOR UPDATE: así distingue un post inexistente (ErrPostNotFound)
+// de uno ajeno (ErrNotPostOwner) sin que un borrado concurrente cambie la respuesta
+// La autorización se repite en el WHERE de la escritura: el autor, o cualquier usuario si anyOwner
+func (r *PostgresRepository) writeOwnedPost(ctx context.Context, id int64, userId int64, anyOwner bool, query string, args ...any) (*models.Post, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // No hace nada si la transacción ya se confirmó
+
+	var ownerId int64
+	err = tx.QueryRowContext(ctx, "SELECT user_id FROM posts WHERE id = $1 FOR UPDATE", id).Scan(&ownerId)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrPostNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ownerId != userId && !anyOwner {
+		return nil, repository.ErrNotPostOwner
+	}
+
+	post, err := scanWrittenPost(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		return nil, err
+	}
+	return post, tx.Commit()
+}
+
+// scanWrittenPost lee el post devuelto por el RETURNING de UpdatePost y DeletePost
+func scanWrittenPost(row *sql.Row) (*models.Post, error) {
+	var post models.Post
+	if err := row.Scan(&post.Id, &post.Title, &post.Content, &post.UserID, &post.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrPostNotFound
+		}
+		return nil, translateError(err)
+	}
+	return &post, nil
+}
+
+func (r *PostgresRepository) GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error) {
+	offset := (page - 1) * limit
+	rows, err := r.db.QueryContext(ctx, "SELECT id, title, content, user_id, created_at FROM posts ORDER BY id DESC LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.Post
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(&post.Id, &post.Title, &post.Content, &post.UserID, &post.CreatedAt); err != nil {
+			return nil, err
+		}
+		posts = append(posts, &post)
+	}
+	return posts, rows.Err()
+}
+
+func (r *PostgresRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	row := r.db.QueryRowContext(ctx, "INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id, created_at", token.UserId, token.TokenHash, token.ExpiresAt.UTC())
+	return translateError(row.Scan(&token.Id, &token.CreatedAt))
+}
+
+func (r *PostgresRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT id, user_id, token_hash, expires_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash = $1", tokenHash)
+
+	var token models.RefreshToken
+	if err := row.Scan(&token.Id, &token.UserId, &token.TokenHash, &token.ExpiresAt, &token.RevokedAt, &token.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PostgresRepository) RevokeRefreshToken(ctx context.Context, id int64) error {
+	// Solo revoca tokens activos, así dos rotaciones concurrentes del mismo token no pueden tener éxito
+	result, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return repository.ErrRefreshTokenRevoked
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RevokeUserRefreshTokens(ctx context.Context, userId int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", time.Now().UTC(), userId)
+	return err
+}
+
+func (r *PostgresRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	// Limpia los registros cuyo token ya expiró: no hace falta recordarlos
+	// Las columnas TIMESTAMP guardan UTC: se compara con la hora UTC de la aplicación y no con NOW(),
+	// que depende de la zona horaria de la sesión
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < $1", time.Now().UTC()); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING", jti, expiresAt.UTC())
+	return err
+}
+
+func (r *PostgresRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)", jti).Scan(&revoked)
+	return revoked, err
+}
+
+func (r *PostgresRepository) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	row := r.db.QueryRowContext(ctx, "INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at", token.UserId, token.Purpose, token.TokenHash, token.ExpiresAt.UTC())
+	return translateError(row.Scan(&token.Id, &token.CreatedAt))
+}
+
+func (r *PostgresRepository) ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (*models.UserToken, error) {
+	// La actualización es atómica: si dos requests usan el mismo token, solo una lo consume
+	row := r.db.QueryRowContext(ctx, `UPDATE user_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING id, user_id, purpose, token_hash, expires_at, used_at, created_at`, time.Now().UTC(), tokenHash, purpose)
+
+	var token models.UserToken
+	if err := row.Scan(&token.Id, &token.UserId, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repository.ErrUserTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PostgresRepository) DeleteUserTokens(ctx context.Context, userId int64, purpose string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2", userId, purpose)
+	return err
+}
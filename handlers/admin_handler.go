@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/middlewares"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/services"
+	"afperdomo2/go/rest-ws/utils"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type AdminMessageResponse struct {
+	Message string `json:"message"`
+}
+
+// ListUsersHandler lista los usuarios registrados (con paginación)
+func ListUsersHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+		if err != nil || limit < 1 {
+			limit = 10
+		}
+
+		users, err := repository.ListUsers(r.Context(), page, limit)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, users)
+	}
+}
+
+// UpdateUserRoleHandler cambia el rol de un usuario
+func UpdateUserRoleHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req UpdateUserRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if !models.IsValidRole(req.Role) {
+			utils.WriteError(w, http.StatusUnprocessableEntity, "Invalid role")
+			return
+		}
+
+		userId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid User ID")
+			return
+		}
+
+		admin, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+		// Evita que un administrador se quite a sí mismo el acceso
+		if admin.Id == userId {
+			utils.WriteError(w, http.StatusForbidden, "Administrators cannot change their own role")
+			return
+		}
+
+		if err := repository.UpdateUserRole(r.Context(), userId, req.Role); err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, AdminMessageResponse{
+			Message: "User role updated successfully",
+		})
+	}
+}
+
+// DeleteUserHandler elimina un usuario
+// Si el usuario tiene posts, responde 409 (los posts lo referencian)
+func DeleteUserHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid User ID")
+			return
+		}
+
+		admin, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+		if admin.Id == userId {
+			utils.WriteError(w, http.StatusForbidden, "Administrators cannot delete their own account")
+			return
+		}
+
+		if err := repository.DeleteUser(r.Context(), userId); err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, AdminMessageResponse{
+			Message: "User deleted successfully",
+		})
+	}
+}
+
+// UnlockUserHandler borra los intentos de login fallidos de un usuario, levantando su bloqueo temporal
+// Los bloqueos por IP no se levantan: expiran solos
+func UnlockUserHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid User ID")
+			return
+		}
+
+		user, err := repository.GetUserById(r.Context(), userId)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		message := "User was not locked"
+		if services.LoginGuardInstance.Unlock(user.Email) {
+			message = "User unlocked successfully"
+		}
+		utils.WriteJSON(w, http.StatusOK, AdminMessageResponse{
+			Message: message,
+		})
+	}
+}
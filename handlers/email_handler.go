@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/middlewares"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/services"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// MAIL_TIMEOUT limita el tiempo de envío de cada email
+const MAIL_TIMEOUT = 30 * time.Second
+
+// VerifyEmailRequest es el body de /email/verify, validado por middlewares.ValidateBody
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+type EmailMessageResponse struct {
+	Message string `json:"message"`
+}
+
+// sendMailInBackground ejecuta send (que envía un email) sin que la respuesta lo espere
+// La tarea se lanza con Server.Background: el apagado del servidor espera a que termine
+func sendMailInBackground(s server.Server, r *http.Request, description string, send func(ctx context.Context) error) {
+	s.Background(r.Context(), func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, MAIL_TIMEOUT)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			slog.ErrorContext(ctx, description+" failed", "error", err)
+		}
+	})
+}
+
+// VerifyEmailHandler confirma el email del usuario con el token recibido por email
+// El token es de un solo uso y no requiere estar autenticado (el enlace puede abrirse en otro dispositivo)
+func VerifyEmailHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		verifyRequest, ok := middlewares.BodyFromContext[VerifyEmailRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		user, err := services.VerificationServiceInstance.Verify(r.Context(), verifyRequest.Token)
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid or expired verification token")
+			return
+		}
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "email verified", "user", user)
+		utils.WriteJSON(w, http.StatusOK, EmailMessageResponse{
+			Message: "Email verified successfully",
+		})
+	}
+}
+
+// ResendVerificationHandler vuelve a enviar el email de verificación al usuario autenticado
+// Los enlaces enviados antes dejan de ser válidos; la ruta se limita en BindRoutes para evitar abusos
+// El email se envía en segundo plano, como el del registro: un cliente que corta la conexión
+// no deja a medias el envío tras invalidar los enlaces anteriores
+func ResendVerificationHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+		if user.IsVerified() {
+			utils.WriteError(w, http.StatusConflict, "Email already verified")
+			return
+		}
+
+		sendMailInBackground(s, r, "verification email", func(ctx context.Context) error {
+			return services.VerificationServiceInstance.SendVerification(ctx, s, user)
+		})
+
+		utils.WriteJSON(w, http.StatusAccepted, EmailMessageResponse{
+			Message: "Verification email sent",
+		})
+	}
+}
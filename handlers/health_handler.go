@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Estados de las sondas de salud
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// readinessTimeout es el tiempo máximo de cada comprobación de /readyz
+const readinessTimeout = 2 * time.Second
+
+// HealthCheck es el resultado de una comprobación de /readyz
+type HealthCheck struct {
+	Status   string `json:"status"`             // "ok" o "unavailable"
+	Error    string `json:"error,omitempty"`    // Motivo del fallo
+	Duration string `json:"duration,omitempty"` // Tiempo que tardó la comprobación
+}
+
+// HealthResponse es el cuerpo de /healthz y /readyz
+type HealthResponse struct {
+	Status string                 `json:"status"`           // "ok" si todas las comprobaciones pasan
+	Checks map[string]HealthCheck `json:"checks,omitempty"` // Detalle de cada comprobación (solo /readyz)
+}
+
+// HealthzHandler es la sonda de vida (liveness): responde 200 mientras el proceso atienda requests
+// No consulta dependencias, para que una caída de la base de datos no provoque reinicios del proceso
+//
+// Respuesta:
+//   - Status Code: 200 (OK)
+//   - Body: {"status": "ok"}
+func HealthzHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		utils.WriteJSON(w, http.StatusOK, HealthResponse{Status: HealthStatusOK})
+	}
+}
+
+// ReadyzHandler es la sonda de disponibilidad (readiness): indica si el servidor puede recibir tráfico
+// Comprueba que la base de datos responde a un ping, que el Hub de WebSockets está activo
+// y que el servidor no está apagándose
+//
+// Respuesta:
+//   - Status Code: 200 (OK) si todas las comprobaciones pasan, 503 (Service Unavailable) si alguna falla
+//   - Body: {"status": "ok", "checks": {"database": {"status": "ok", "duration": "1.2ms"}, "websocket_hub": {"status": "ok"}, "shutdown": {"status": "ok"}}}
+func ReadyzHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		checks := map[string]HealthCheck{
+			"database":      checkDatabase(r.Context()),
+			"websocket_hub": checkCondition(s.Hub().Running(), "websocket hub is not running"),
+			"shutdown":      checkCondition(!s.ShuttingDown(), "server is shutting down"),
+		}
+
+		response := HealthResponse{Status: HealthStatusOK, Checks: checks}
+		status := http.StatusOK
+		for _, check := range checks {
+			if check.Status != HealthStatusOK {
+				response.Status = HealthStatusUnavailable
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		utils.WriteJSON(w, status, response)
+	}
+}
+
+// checkDatabase hace ping a la base de datos a través del repositorio
+// /readyz es público: el error del driver (que puede incluir host, puerto o usuario) solo va al log
+func checkDatabase(ctx context.Context) HealthCheck {
+	pingCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := repository.Ping(pingCtx)
+	if err != nil {
+		slog.WarnContext(ctx, "readiness check failed", "check", "database", "error", err)
+	}
+	check := checkCondition(err == nil, "database is unavailable")
+	check.Duration = time.Since(start).String()
+	return check
+}
+
+// checkCondition convierte una condición en el resultado de una comprobación
+func checkCondition(ok bool, reason string) HealthCheck {
+	if ok {
+		return HealthCheck{Status: HealthStatusOK}
+	}
+	return HealthCheck{Status: HealthStatusUnavailable, Error: reason}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/utils"
+	"net/http"
+)
+
+// JWKSHandler publica las claves públicas con las que se verifican los access tokens (RFC 7517)
+// Otros servicios pueden verificar los tokens emitidos por esta API sin conocer ningún secreto,
+// eligiendo la clave por el kid del header del token. Las claves HMAC nunca se publican
+//
+// Respuesta:
+//   - Status Code: 200 (OK)
+//   - Body: {"keys": [{"kty": "RSA", "kid": "...", "use": "sig", "alg": "RS256", "n": "...", "e": "AQAB"}]}
+func JWKSHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Permite que los clientes cacheen las claves durante un tiempo corto, para que las
+		// nuevas claves de una rotación se descubran pronto
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		utils.WriteJSON(w, http.StatusOK, s.Keys().JWKS())
+	}
+}
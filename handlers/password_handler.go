@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/middlewares"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/services"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// ForgotPasswordRequest es el body de /password/forgot, validado por middlewares.ValidateBody
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+}
+
+// ResetPasswordRequest es el body de /password/reset, validado por middlewares.ValidateBody
+// La nueva contraseña debe cumplir la política de utils.Validate
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=128"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type PasswordMessageResponse struct {
+	Message string `json:"message"`
+}
+
+// ForgotPasswordHandler envía un email con un enlace para restablecer la contraseña
+// Responde siempre 202 con el mismo mensaje, exista o no el email: el email se envía en segundo plano
+// para que tampoco el tiempo de respuesta revele qué cuentas existen
+func ForgotPasswordHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		forgotRequest, ok := middlewares.BodyFromContext[ForgotPasswordRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		sendMailInBackground(s, r, "password reset email", func(ctx context.Context) error {
+			return services.PasswordServiceInstance.RequestReset(ctx, s, forgotRequest.Email)
+		})
+
+		utils.WriteJSON(w, http.StatusAccepted, PasswordMessageResponse{
+			Message: "If the email is registered, you will receive a link to reset your password",
+		})
+	}
+}
+
+// ResetPasswordHandler cambia la contraseña con el token recibido por email
+// El token es de un solo uso; tras el cambio se cierran todas las sesiones del usuario
+func ResetPasswordHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resetRequest, ok := middlewares.BodyFromContext[ResetPasswordRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		err := services.PasswordServiceInstance.ResetPassword(r.Context(), resetRequest.Token, resetRequest.Password)
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "password reset")
+		utils.WriteJSON(w, http.StatusOK, PasswordMessageResponse{
+			Message: "Password updated successfully",
+		})
+	}
+}
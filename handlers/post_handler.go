@@ -1,188 +1,232 @@
-package handlers
-
-import (
-	"afperdomo2/go/rest-ws/models"
-	"afperdomo2/go/rest-ws/repository"
-	"afperdomo2/go/rest-ws/server"
-	"afperdomo2/go/rest-ws/services"
-	"encoding/json"
-	"log"
-	"net/http"
-	"strconv"
-
-	"github.com/gorilla/mux"
-)
-
-type UpsertPostRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
-}
-
-type PostUpdateResponse struct {
-	Message string `json:"message"`
-}
-
-func CreatePostHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req UpsertPostRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		user := services.UserServiceInstance.GetUserFromToken(r, s, w)
-
-		post := models.Post{
-			Title:   req.Title,
-			Content: req.Content,
-			UserID:  user.Id,
-		}
-
-		err := repository.CreatePost(r.Context(), &post)
-		if err != nil {
-			http.Error(w, "Error creating post: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Enviar mensaje a WebSocket
-		postMessage := models.WebSocketMessage{
-			Type:    "post_created",
-			Payload: post,
-		}
-		log.Println("📬 Enviando mensaje de WebSocket:", postMessage)
-		s.Hub().SendMessageToClients(postMessage, nil) // Broadcast a todos los clientes conectados
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(post)
-	}
-}
-
-func UpdatePostHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req UpsertPostRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		postIdStr := mux.Vars(r)["id"]
-		if postIdStr == "" {
-			http.Error(w, "Post ID is required", http.StatusBadRequest)
-			return
-		}
-
-		postId, err := strconv.ParseInt(postIdStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid Post ID", http.StatusBadRequest)
-			return
-		}
-
-		post := &models.Post{
-			Id:      postId,
-			Title:   req.Title,
-			Content: req.Content,
-		}
-
-		err = repository.UpdatePost(r.Context(), postId, post)
-		if err != nil {
-			http.Error(w, "Error updating post: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(PostUpdateResponse{
-			Message: "Post updated successfully",
-		})
-	}
-}
-
-func GetPostByIdHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		postIdStr := mux.Vars(r)["id"]
-		if postIdStr == "" {
-			http.Error(w, "Post ID is required", http.StatusBadRequest)
-			return
-		}
-
-		// Convert postIdStr to int64
-		postId, err := strconv.ParseInt(postIdStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid Post ID", http.StatusBadRequest)
-			return
-		}
-
-		post, err := repository.GetPostById(r.Context(), postId)
-		if err != nil {
-			http.Error(w, "Error fetching post: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if post == nil {
-			http.Error(w, "Post not found", http.StatusNotFound)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(post)
-	}
-}
-
-func DeletePostHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		postIdStr := mux.Vars(r)["id"]
-		if postIdStr == "" {
-			http.Error(w, "Post ID is required", http.StatusBadRequest)
-			return
-		}
-
-		postId, err := strconv.ParseInt(postIdStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid Post ID", http.StatusBadRequest)
-			return
-		}
-
-		user := services.UserServiceInstance.GetUserFromToken(r, s, w)
-
-		err = repository.DeletePost(r.Context(), postId, user.Id)
-		if err != nil {
-			http.Error(w, "Error deleting post: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(PostUpdateResponse{
-			Message: "Post deleted successfully",
-		})
-	}
-}
-
-func GetAllPostsHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		pageStr := r.URL.Query().Get("page")
-		limitStr := r.URL.Query().Get("limit")
-
-		page, err := strconv.ParseInt(pageStr, 10, 64)
-		if err != nil || page < 1 {
-			page = 1
-		}
-
-		limit, err := strconv.ParseInt(limitStr, 10, 64)
-		if err != nil || limit < 1 {
-			limit = 10
-		}
-
-		posts, err := repository.GetAllPosts(r.Context(), page, limit)
-		if err != nil {
-			http.Error(w, "Error fetching posts: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(posts)
-	}
-}
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/middlewares"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/services"
+	"afperdomo2/go/rest-ws/utils"
+	"afperdomo2/go/rest-ws/websockets"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// UpsertPostRequest es el body para crear y actualizar posts, validado por middlewares.ValidateBody
+// El título cabe en la columna posts.title (VARCHAR(255)); el contenido (TEXT) no tiene límite
+type UpsertPostRequest struct {
+	Title   string `json:"title" validate:"required,max=255"`
+	Content string `json:"content" validate:"required"`
+}
+
+type PostUpdateResponse struct {
+	Message string `json:"message"`
+}
+
+func CreatePostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := middlewares.BodyFromContext[UpsertPostRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		user, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+
+		post := models.Post{
+			Title:   req.Title,
+			Content: req.Content,
+			UserID:  user.Id,
+		}
+
+		err := repository.CreatePost(r.Context(), &post)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		// Enviar mensaje a WebSocket
+		publishPostEvent(r.Context(), s, models.MessageTypePostCreated, post.UserID, post)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(post)
+	}
+}
+
+func UpdatePostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := middlewares.BodyFromContext[UpsertPostRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		postIdStr := mux.Vars(r)["id"]
+		if postIdStr == "" {
+			utils.WriteError(w, http.StatusBadRequest, "Post ID is required")
+			return
+		}
+
+		postId, err := strconv.ParseInt(postIdStr, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid Post ID")
+			return
+		}
+
+		user, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+
+		changes := &models.Post{
+			Id:      postId,
+			Title:   req.Title,
+			Content: req.Content,
+		}
+
+		updated, err := services.PostServiceInstance.UpdatePost(r.Context(), user, postId, changes)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		// Enviar mensaje a WebSocket con el post actualizado
+		publishPostEvent(r.Context(), s, models.MessageTypePostUpdated, updated.UserID, updated)
+		notifyPostAuthor(r.Context(), s, user, updated, models.PostActionUpdated)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(PostUpdateResponse{
+			Message: "Post updated successfully",
+		})
+	}
+}
+
+func GetPostByIdHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postIdStr := mux.Vars(r)["id"]
+		if postIdStr == "" {
+			utils.WriteError(w, http.StatusBadRequest, "Post ID is required")
+			return
+		}
+
+		// Convert postIdStr to int64
+		postId, err := strconv.ParseInt(postIdStr, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid Post ID")
+			return
+		}
+
+		post, err := repository.GetPostById(r.Context(), postId)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(post)
+	}
+}
+
+func DeletePostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postIdStr := mux.Vars(r)["id"]
+		if postIdStr == "" {
+			utils.WriteError(w, http.StatusBadRequest, "Post ID is required")
+			return
+		}
+
+		postId, err := strconv.ParseInt(postIdStr, 10, 64)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid Post ID")
+			return
+		}
+
+		user, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+
+		deleted, err := services.PostServiceInstance.DeletePost(r.Context(), user, postId)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		// Enviar mensaje a WebSocket
+		publishPostEvent(r.Context(), s, models.MessageTypePostDeleted, deleted.UserID, models.PostDeletedPayload{
+			Id:     deleted.Id,
+			UserID: deleted.UserID,
+		})
+		notifyPostAuthor(r.Context(), s, user, deleted, models.PostActionDeleted)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(PostUpdateResponse{
+			Message: "Post deleted successfully",
+		})
+	}
+}
+
+func GetAllPostsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pageStr := r.URL.Query().Get("page")
+		limitStr := r.URL.Query().Get("limit")
+
+		page, err := strconv.ParseInt(pageStr, 10, 64)
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit < 1 {
+			limit = 10
+		}
+
+		posts, err := repository.GetAllPosts(r.Context(), page, limit)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(posts)
+	}
+}
+
+// publishPostEvent publica un evento de posts a los suscriptores de todos los posts
+// y a los suscriptores de los posts del autor
+func publishPostEvent(ctx context.Context, s server.Server, messageType string, authorId int64, payload any) {
+	message := models.NewWebSocketMessage(messageType, payload)
+	slog.DebugContext(ctx, "publishing websocket event", "type", message.Type, "author_id", authorId)
+	s.Hub().PublishToTopics(message, websockets.TopicPosts, websockets.UserPostsTopic(authorId))
+}
+
+// notifyPostAuthor avisa en privado al autor de un post cuando otro usuario (un moderador
+// o un administrador) lo modifica; los cambios del propio autor no generan aviso
+func notifyPostAuthor(ctx context.Context, s server.Server, user *models.User, post *models.Post, action string) {
+	if user.Id == post.UserID {
+		return
+	}
+	message := models.NewWebSocketMessage(models.MessageTypePostModerated, models.PostModeratedPayload{
+		Id:          post.Id,
+		Action:      action,
+		ModeratorId: user.Id,
+	})
+	slog.DebugContext(ctx, "notifying post author", "post_id", post.Id, "author_id", post.UserID, "action", action)
+	s.Hub().SendMessageToUser(post.UserID, message)
+}
@@ -1,123 +1,225 @@
-package handlers
-
-import (
-	"afperdomo2/go/rest-ws/models"
-	"afperdomo2/go/rest-ws/repository"
-	"afperdomo2/go/rest-ws/server"
-	"afperdomo2/go/rest-ws/services"
-	"encoding/json"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/golang-jwt/jwt"
-	"golang.org/x/crypto/bcrypt"
-)
-
-const (
-	HASH_COST = 8
-)
-
-type SignupRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type SignupResponse struct {
-	Email string `json:"email"`
-}
-
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-type LoginResponse struct {
-	Email string `json:"email"`
-	Token string `json:"token"`
-}
-
-func SingUpHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var signupRequest SignupRequest
-		if err := json.NewDecoder(r.Body).Decode(&signupRequest); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signupRequest.Password), HASH_COST)
-		if err != nil {
-			http.Error(w, "Error hashing password: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		var newUser = models.User{
-			Email:    signupRequest.Email,
-			Password: string(hashedPassword),
-		}
-
-		err = repository.CreateUser(r.Context(), &newUser)
-		if err != nil {
-			http.Error(w, "Error creating user: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		log.Printf("User created successfully: %v", newUser)
-		json.NewEncoder(w).Encode(SignupResponse{
-			Email: newUser.Email,
-		})
-	}
-}
-
-func LoginHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var loginRequest LoginRequest
-		if err := json.NewDecoder(r.Body).Decode(&loginRequest); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-
-		user, err := repository.GetUserByEmail(r.Context(), loginRequest.Email)
-		if err != nil {
-			http.Error(w, "Error retrieving user: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password)); err != nil {
-			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
-			return
-		}
-
-		claims := &models.AppClaims{
-			UserId: user.Id,
-			StandardClaims: jwt.StandardClaims{
-				ExpiresAt: jwt.TimeFunc().Add(48 * time.Hour).Unix(),
-			},
-		}
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		tokenString, err := token.SignedString([]byte(s.Config().JWTSecret))
-		if err != nil {
-			http.Error(w, "Error signing token: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(LoginResponse{
-			Email: user.Email,
-			Token: tokenString,
-		})
-	}
-}
-
-// Valida el token JWT y devuelve el usuario asociado
-func GetUserFromTokenHandler(s server.Server) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		user := services.UserServiceInstance.GetUserFromToken(r, s, w)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(user)
-	}
-}
+package handlers
+
+import (
+	"afperdomo2/go/rest-ws/middlewares"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/services"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HASH_COST = services.PASSWORD_HASH_COST // Debe coincidir con el del hash ficticio del login
+)
+
+// SignupRequest es el body de /signup, validado por middlewares.ValidateBody
+// El email cabe en la columna users.email (VARCHAR(100)) y la contraseña cumple la política de utils.Validate
+type SignupRequest struct {
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type SignupResponse struct {
+	Email string `json:"email"`
+}
+
+// LoginRequest es el body de /login, validado por middlewares.ValidateBody
+// La contraseña no se valida contra la política: las cuentas antiguas pueden no cumplirla
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+type LoginResponse struct {
+	Email        string `json:"email"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutResponse struct {
+	Message string `json:"message"`
+}
+
+func SingUpHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		signupRequest, ok := middlewares.BodyFromContext[SignupRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signupRequest.Password), HASH_COST)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		var newUser = models.User{
+			Email:    signupRequest.Email,
+			Password: string(hashedPassword),
+		}
+
+		err = repository.CreateUser(r.Context(), &newUser)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		// El email de verificación se envía en segundo plano: un fallo del correo no impide el registro
+		// y el usuario puede pedir otro con /email/verify/resend
+		sendMailInBackground(s, r, "verification email", func(ctx context.Context) error {
+			return services.VerificationServiceInstance.SendVerification(ctx, s, &newUser)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		slog.InfoContext(r.Context(), "user created", "user", newUser)
+		json.NewEncoder(w).Encode(SignupResponse{
+			Email: newUser.Email,
+		})
+	}
+}
+
+func LoginHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loginRequest, ok := middlewares.BodyFromContext[LoginRequest](r.Context())
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		// Protección contra fuerza bruta: tras varios fallos con el mismo email o desde la misma IP
+		// hay que esperar antes de reintentar (también con la contraseña correcta)
+		// Check reserva el intento: se resuelve con Fail, Succeed o Release
+		clientIP := utils.ClientIP(r)
+		if wait := services.LoginGuardInstance.Check(loginRequest.Email, clientIP); wait > 0 {
+			utils.WriteTooManyRequests(w, wait, "Too many failed login attempts, try again later")
+			return
+		}
+
+		user, err := services.UserServiceInstance.Login(r.Context(), loginRequest.Email, loginRequest.Password)
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			if wait := services.LoginGuardInstance.Fail(loginRequest.Email, clientIP); wait > 0 {
+				slog.WarnContext(r.Context(), "login throttled after repeated failures", "client_ip", clientIP, "retry_after", wait.String())
+			}
+			utils.WriteUnauthorizedMessage(w, nil, "Invalid email or password")
+			return
+		}
+		if err != nil {
+			services.LoginGuardInstance.Release(loginRequest.Email, clientIP)
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+		services.LoginGuardInstance.Succeed(loginRequest.Email, clientIP)
+
+		tokens, err := services.TokenServiceInstance.IssueTokens(r.Context(), s, user)
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		// Los clientes de navegador usan la cookie HttpOnly; el resto, el token del body
+		utils.SetAccessTokenCookie(w, tokens.AccessToken, s.Config().AccessTokenTTL, s.Config().AuthCookieSecure)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LoginResponse{
+			Email:        user.Email,
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
+		})
+	}
+}
+
+// RefreshTokenHandler rota el refresh token recibido y emite un nuevo par de tokens
+// Cada refresh token solo puede usarse una vez
+func RefreshTokenHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var refreshRequest RefreshTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&refreshRequest); err != nil || refreshRequest.RefreshToken == "" {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		user, tokens, err := services.TokenServiceInstance.Refresh(r.Context(), s, refreshRequest.RefreshToken)
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			utils.WriteUnauthorizedMessage(w, nil, "Invalid refresh token")
+			return
+		}
+		if err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+
+		utils.SetAccessTokenCookie(w, tokens.AccessToken, s.Config().AccessTokenTTL, s.Config().AuthCookieSecure)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LoginResponse{
+			Email:        user.Email,
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
+		})
+	}
+}
+
+// LogoutHandler revoca el access token actual y, opcionalmente, el refresh token enviado en el body
+// También elimina la cookie del access token de los clientes de navegador
+func LogoutHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var logoutRequest RefreshTokenRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&logoutRequest); err != nil {
+				utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+
+		claims, ok := middlewares.ClaimsFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+
+		if err := services.TokenServiceInstance.Logout(r.Context(), claims, logoutRequest.RefreshToken); err != nil {
+			utils.WriteDomainError(w, r, err)
+			return
+		}
+		utils.ClearAccessTokenCookie(w, s.Config().AuthCookieSecure)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LogoutResponse{
+			Message: "Logged out successfully",
+		})
+	}
+}
+
+// Devuelve el usuario autenticado (cargado por CheckAuthMiddleware a partir del token JWT)
+func GetUserFromTokenHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := middlewares.UserFromContext(r.Context())
+		if !ok {
+			utils.WriteUnauthorized(w, nil)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	}
+}
@@ -0,0 +1,114 @@
+// Package logging configura el logger estructurado (log/slog) de la API
+// Todas las líneas registradas con un contexto incluyen el request_id de la request en curso
+// y los atributos sensibles (contraseñas, tokens, secretos) se ocultan antes de escribirse
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	FormatText = "text" // Formato clave=valor, cómodo para desarrollo (por defecto)
+	FormatJSON = "json" // Una línea JSON por registro, para producción
+
+	// Redacted reemplaza el valor de los atributos sensibles
+	Redacted = "[REDACTED]"
+)
+
+var ErrInvalidFormat = errors.New("invalid log format")
+
+// sensitiveKeys son los atributos cuyo valor nunca se escribe en el log
+// La comparación no distingue mayúsculas ni guiones: "Refresh-Token" equivale a "refresh_token"
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+}
+
+// requestIdKey es la clave privada con la que se guarda el request ID en el contexto
+type requestIdKey struct{}
+
+// WithRequestID devuelve un contexto que lleva el request ID
+func WithRequestID(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// RequestIDFromContext devuelve el request ID del contexto ("" si no hay)
+func RequestIDFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+// ParseLevel interpreta un nivel de log: debug, info (por defecto), warn o error
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	err := parsed.UnmarshalText([]byte(level))
+	return parsed, err
+}
+
+// New crea un logger con el formato y el nivel indicados, que añade el request_id
+// y oculta los atributos sensibles
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var handler slog.Handler
+	switch format {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, ErrInvalidFormat
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// redact reemplaza el valor de los atributos sensibles
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitive(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+// Milliseconds expresa una duración en milisegundos (con decimales) para los atributos duration_ms
+func Milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+// IsSensitive indica si el atributo con esa clave contiene credenciales
+func IsSensitive(key string) bool {
+	return sensitiveKeys[strings.ReplaceAll(strings.ToLower(key), "-", "_")]
+}
+
+// contextHandler añade a cada registro el request_id del contexto, si existe
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestIDFromContext(ctx); requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
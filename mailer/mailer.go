@@ -0,0 +1,77 @@
+// Package mailer envía los emails de la API (p. ej. el enlace para restablecer la contraseña)
+// Mailer abstrae la entrega: WriterMailer escribe los mensajes en la salida estándar o en un archivo
+// (desarrollo) y SMTPMailer los entrega a un servidor SMTP (producción)
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidMessage = errors.New("invalid email message")
+
+// Message es un email de texto plano
+type Message struct {
+	To      string // Dirección del destinatario
+	Subject string
+	Body    string
+}
+
+// validate rechaza mensajes sin destinatario y saltos de línea en los headers,
+// que permitirían inyectar headers o destinatarios adicionales
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
+
+// Mailer entrega emails
+// Send debe respetar la cancelación y el deadline del contexto
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// WriterMailer escribe cada email, legible y sin codificar, en un io.Writer
+// Pensado para desarrollo: los enlaces de los emails se pueden copiar desde la consola o el archivo
+type WriterMailer struct {
+	mutex sync.Mutex
+	w     io.Writer
+	from  string
+}
+
+// NewWriterMailer crea un WriterMailer que escribe en w (ej: os.Stdout)
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+// NewFileMailer crea un WriterMailer que añade los emails al final del archivo indicado
+// El archivo se crea con permisos 0600: los emails contienen tokens de un solo uso
+func NewFileMailer(path string, from string) (*WriterMailer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterMailer(file, from), nil
+}
+
+func (m *WriterMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, err := fmt.Fprintf(m.w, "----- email -----\nFrom: %s\nTo: %s\nDate: %s\nSubject: %s\n\n%s\n-----------------\n",
+		m.from, message.To, time.Now().Format(time.RFC1123Z), message.Subject, message.Body)
+	return err
+}
@@ -0,0 +1,82 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriterMailerSend(t *testing.T) {
+	var buffer bytes.Buffer
+	m := NewWriterMailer(&buffer, "no-reply@x.io")
+
+	err := m.Send(context.Background(), Message{To: "ana@x.io", Subject: "Verifica tu email", Body: "Tu código: ñ123"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	// Sin codificar: los enlaces y tokens se pueden copiar tal cual
+	pattern := regexp.MustCompile(`^----- email -----\nFrom: no-reply@x\.io\nTo: ana@x\.io\nDate: [^\n]+\n` +
+		`Subject: Verifica tu email\n\nTu código: ñ123\n-----------------\n$`)
+	if !pattern.MatchString(buffer.String()) {
+		t.Fatalf("unexpected output:\n%s", buffer.String())
+	}
+}
+
+func TestWriterMailerRejects(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		message Message
+		want    error
+	}{
+		{"header injection in recipient", context.Background(), Message{To: "ana@x.io\nBcc: eve@x.io", Subject: "Hola"}, ErrInvalidMessage},
+		{"header injection in subject", context.Background(), Message{To: "ana@x.io", Subject: "Hola\r\nBcc: eve@x.io"}, ErrInvalidMessage},
+		{"canceled context", canceled, Message{To: "ana@x.io", Subject: "Hola"}, context.Canceled},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			err := NewWriterMailer(&buffer, "no-reply@x.io").Send(tc.ctx, tc.message)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Send = %v, want %v", err, tc.want)
+			}
+			if buffer.Len() > 0 {
+				t.Fatalf("rejected message was written:\n%s", buffer.String())
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	for _, to := range []string{"ana@x.io", "bob@x.io"} {
+		m, err := NewFileMailer(path, "no-reply@x.io")
+		if err != nil {
+			t.Fatalf("NewFileMailer: %v", err)
+		}
+		if err := m.Send(context.Background(), Message{To: to, Subject: "Hola", Body: "Hola"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	// Los emails se añaden al final y el archivo solo lo puede leer su propietario
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Count(string(content), "----- email -----") != 2 || !strings.Contains(string(content), "To: bob@x.io") {
+		t.Errorf("file content:\n%s", content)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig son los datos de conexión al servidor SMTP
+type SMTPConfig struct {
+	Host     string // Host del servidor SMTP (ej: smtp.example.com)
+	Port     string // Puerto (por defecto "587")
+	Username string // Usuario para AUTH PLAIN; vacío: sin autenticación
+	Password string
+	From     string // Remitente de los emails (ej: no-reply@example.com)
+}
+
+// SMTPMailer entrega los emails a un servidor SMTP
+// Usa STARTTLS si el servidor lo anuncia; net/smtp solo permite AUTH PLAIN sobre TLS
+// o contra localhost, así las credenciales nunca viajan en claro por la red
+type SMTPMailer struct {
+	config SMTPConfig
+}
+
+// NewSMTPMailer valida la configuración y crea el SMTPMailer
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP host must be specified")
+	}
+	if config.From == "" {
+		return nil, errors.New("mail sender must be specified")
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return &SMTPMailer{config: config}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	// net/smtp no acepta contextos: se usa el deadline del contexto en la conexión
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.config.Host, m.config.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.config.Host}); err != nil {
+			return err
+		}
+	}
+	if m.config.Username != "" {
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(m.format(message)); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// format construye el mensaje MIME: asunto codificado (RFC 2047) y cuerpo quoted-printable,
+// para que los caracteres no ASCII lleguen intactos aunque el servidor no soporte 8BITMIME
+func (m *SMTPMailer) format(message Message) []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", m.config.From)
+	fmt.Fprintf(&buffer, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buffer)
+	body.Write([]byte(strings.ReplaceAll(message.Body, "\n", "\r\n")))
+	body.Close()
+	return buffer.Bytes()
+}
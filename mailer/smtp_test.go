@@ -0,0 +1,212 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer es un servidor SMTP mínimo que acepta una conexión y guarda lo recibido
+type fakeSMTPServer struct {
+	listener net.Listener
+	rcptCode int // Respuesta a RCPT TO (250 si es 0)
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return &fakeSMTPServer{listener: listener, done: make(chan struct{})}
+}
+
+// config devuelve la configuración de un SMTPMailer que entrega a este servidor
+func (f *fakeSMTPServer) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	return SMTPConfig{Host: host, Port: port, Username: "user", Password: "secret", From: "no-reply@x.io"}
+}
+
+// serve atiende una sesión SMTP en segundo plano; wait espera a que termine
+func (f *fakeSMTPServer) serve(t *testing.T) {
+	go func() {
+		defer close(f.done)
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			f.commands = append(f.commands, line)
+			verb, _, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO":
+				text.PrintfLine("250-localhost")
+				text.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				text.PrintfLine("235 2.7.0 Authentication successful")
+			case "RCPT":
+				if f.rcptCode != 0 {
+					text.PrintfLine("%d 5.1.1 Mailbox unavailable", f.rcptCode)
+					continue
+				}
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := io.ReadAll(text.DotReader())
+				if err != nil {
+					t.Errorf("reading DATA: %v", err)
+					return
+				}
+				f.data = string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("250 OK")
+			}
+		}
+	}()
+}
+
+func (f *fakeSMTPServer) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-f.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not finish")
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.serve(t)
+	m, err := NewSMTPMailer(server.config())
+	if err != nil {
+		t.Fatalf("NewSMTPMailer: %v", err)
+	}
+
+	body := "Hola Ana,\n\nAbre este enlace para restablecer tu contraseña:\n" +
+		"https://app.example.com/reset?token=" + strings.Repeat("a1b2", 20) + "\n.\nUn saludo"
+	err = m.Send(context.Background(), Message{To: "ana@x.io", Subject: "Restablece tu contraseña", Body: body})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	server.wait(t)
+
+	// Sobre: EHLO, AUTH PLAIN (\x00user\x00secret), remitente y destinatario
+	want := []string{"EHLO localhost", "AUTH PLAIN AHVzZXIAc2VjcmV0", "MAIL FROM:<no-reply@x.io>", "RCPT TO:<ana@x.io>", "DATA", "QUIT"}
+	if strings.Join(server.commands, "|") != strings.Join(want, "|") {
+		t.Fatalf("commands = %q, want %q", server.commands, want)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("parsing message: %v", err)
+	}
+	headers := map[string]string{
+		"From":                      "no-reply@x.io",
+		"To":                        "ana@x.io",
+		"Subject":                   "=?utf-8?q?Restablece_tu_contrase=C3=B1a?=",
+		"Mime-Version":              "1.0",
+		"Content-Type":              "text/plain; charset=utf-8",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}
+	for name, value := range headers {
+		if got := message.Header.Get(name); got != value {
+			t.Errorf("header %s = %q, want %q", name, got, value)
+		}
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject")); err != nil || subject != "Restablece tu contraseña" {
+		t.Errorf("decoded subject = %q (%v)", subject, err)
+	}
+	if _, err := message.Header.Date(); err != nil {
+		t.Errorf("Date header: %v", err)
+	}
+
+	// Cuerpo quoted-printable: líneas de 76 caracteres como máximo, solo ASCII, y decodifica al original
+	raw, err := io.ReadAll(message.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	// DotReader deshace el dot-stuffing y normaliza los CRLF a LF
+	for _, line := range strings.Split(string(raw), "\n") {
+		if len(line) > 76 {
+			t.Errorf("body line longer than 76 characters: %q", line)
+		}
+	}
+	if strings.ContainsFunc(string(raw), func(r rune) bool { return r > 127 }) {
+		t.Error("encoded body contains non-ASCII characters")
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(raw))))
+	if err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if want := body + "\n"; string(decoded) != want { // net/smtp termina los datos con un salto de línea
+		t.Errorf("decoded body = %q, want %q", decoded, want)
+	}
+}
+
+func TestSMTPMailerRecipientRejected(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.rcptCode = 550
+	server.serve(t)
+	m, _ := NewSMTPMailer(server.config())
+
+	err := m.Send(context.Background(), Message{To: "nobody@x.io", Subject: "Hola", Body: "Hola"})
+	var protocolErr *textproto.Error
+	if !errors.As(err, &protocolErr) || protocolErr.Code != 550 {
+		t.Fatalf("Send = %v, want a 550 error", err)
+	}
+}
+
+func TestSMTPMailerRejectsInvalidMessages(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	m, _ := NewSMTPMailer(server.config())
+
+	// Se rechazan antes de conectar: el servidor nunca recibe la conexión
+	messages := []Message{
+		{To: "", Subject: "Hola", Body: "Hola"},
+		{To: "ana@x.io\r\nBcc: eve@x.io", Subject: "Hola", Body: "Hola"},
+		{To: "ana@x.io", Subject: "Hola\nBcc: eve@x.io", Body: "Hola"},
+	}
+	for _, message := range messages {
+		if err := m.Send(context.Background(), message); !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("Send(%q, %q) = %v, want ErrInvalidMessage", message.To, message.Subject, err)
+		}
+	}
+}
+
+func TestNewSMTPMailer(t *testing.T) {
+	if _, err := NewSMTPMailer(SMTPConfig{From: "no-reply@x.io"}); err == nil {
+		t.Error("NewSMTPMailer without host: want error")
+	}
+	if _, err := NewSMTPMailer(SMTPConfig{Host: "smtp.x.io"}); err == nil {
+		t.Error("NewSMTPMailer without sender: want error")
+	}
+	m, err := NewSMTPMailer(SMTPConfig{Host: "smtp.x.io", From: "no-reply@x.io"})
+	if err != nil || m.config.Port != "587" {
+		t.Errorf("NewSMTPMailer default port = %v (%v), want 587", m, err)
+	}
+}
@@ -1,54 +1,219 @@
-package main
-
-import (
-	"afperdomo2/go/rest-ws/handlers"
-	"afperdomo2/go/rest-ws/middlewares"
-	"afperdomo2/go/rest-ws/server"
-	"context"
-	"log"
-	"net/http"
-	"os"
-
-	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
-)
-
-func main() {
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-	}
-	PORT := os.Getenv("PORT")
-	JWT_SECRET := os.Getenv("JWT_SECRET")
-	DATABASE_URL := os.Getenv("DATABASE_URL")
-
-	s, error := server.NewServer(context.Background(), &server.ServerConfig{
-		Port:        ":" + PORT,
-		JWTSecret:   JWT_SECRET,
-		DatabaseURL: DATABASE_URL,
-	})
-	if error != nil {
-		log.Fatalf("Error creating server: %v", error)
-	}
-	s.Start(BindRoutes)
-}
-
-func BindRoutes(s server.Server, r *mux.Router) {
-	api := r.PathPrefix("/api/v1").Subrouter()  // Subrouter para agrupar las rutas de la API
-	api.Use(middlewares.CheckAuthMiddleware(s)) // Middleware de autenticación para todas las rutas de la API
-
-	// 1. Endpoints
-	api.HandleFunc("/", handlers.HomeHandler(s)).Methods(http.MethodGet)
-	api.HandleFunc("/signup", handlers.SingUpHandler(s)).Methods(http.MethodPost)
-	api.HandleFunc("/login", handlers.LoginHandler(s)).Methods(http.MethodPost)
-	api.HandleFunc("/user-info", handlers.GetUserFromTokenHandler(s)).Methods(http.MethodGet)
-
-	api.HandleFunc("/posts/{id:[0-9]+}", handlers.GetPostByIdHandler(s)).Methods(http.MethodGet)
-	api.HandleFunc("/posts/{id:[0-9]+}", handlers.UpdatePostHandler(s)).Methods(http.MethodPut)
-	api.HandleFunc("/posts/{id:[0-9]+}", handlers.DeletePostHandler(s)).Methods(http.MethodDelete)
-	api.HandleFunc("/posts", handlers.CreatePostHandler(s)).Methods(http.MethodPost)
-	api.HandleFunc("/posts", handlers.GetAllPostsHandler(s)).Methods(http.MethodGet)
-
-	// 2. WebSocket
-	r.HandleFunc("/ws", s.Hub().WebSocketHandler)
-}
+package main
+
+import (
+	"afperdomo2/go/rest-ws/handlers"
+	"afperdomo2/go/rest-ws/logging"
+	"afperdomo2/go/rest-ws/metrics"
+	"afperdomo2/go/rest-ws/middlewares"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/server"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	err := godotenv.Load(".env")
+	if err != nil {
+		fatal("error loading .env file", err)
+	}
+
+	// Logger estructurado: LOG_LEVEL (debug, info, warn, error) y LOG_FORMAT (text o json)
+	LOG_LEVEL, err := logging.ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		fatal("invalid LOG_LEVEL", err)
+	}
+	logger, err := logging.New(os.Stderr, os.Getenv("LOG_FORMAT"), LOG_LEVEL)
+	if err != nil {
+		fatal("invalid LOG_FORMAT", err)
+	}
+	slog.SetDefault(logger)
+
+	PORT := os.Getenv("PORT")
+	JWT_SECRET := os.Getenv("JWT_SECRET")
+	JWT_ALGORITHM := os.Getenv("JWT_ALGORITHM")
+	JWT_KEYS_DIR := os.Getenv("JWT_KEYS_DIR")
+	JWT_ACTIVE_KID := os.Getenv("JWT_ACTIVE_KID")
+	JWT_ISSUER := os.Getenv("JWT_ISSUER")
+	JWT_AUDIENCE := os.Getenv("JWT_AUDIENCE")
+	JWT_LEEWAY, _ := time.ParseDuration(os.Getenv("JWT_LEEWAY")) // Vacío o inválido: valor por defecto
+	DATABASE_URL := os.Getenv("DATABASE_URL")
+	DATABASE_DRIVER := os.Getenv("DATABASE_DRIVER")
+	DATABASE_AUTO_MIGRATE, _ := strconv.ParseBool(os.Getenv("DATABASE_AUTO_MIGRATE"))
+	ACCESS_TOKEN_TTL, _ := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL"))   // Vacío o inválido: valor por defecto
+	REFRESH_TOKEN_TTL, _ := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")) // Vacío o inválido: valor por defecto
+	WS_SEND_BUFFER, _ := strconv.Atoi(os.Getenv("WS_SEND_BUFFER"))             // Vacío o inválido: valor por defecto
+	WS_SLOW_CONSUMER_POLICY := os.Getenv("WS_SLOW_CONSUMER_POLICY")
+	SHUTDOWN_TIMEOUT, _ := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	SHUTDOWN_DRAIN_DELAY, _ := time.ParseDuration(os.Getenv("SHUTDOWN_DRAIN_DELAY"))
+	AUTH_COOKIE_SECURE, _ := strconv.ParseBool(os.Getenv("AUTH_COOKIE_SECURE")) // Vacío o inválido: false
+	CORS_ALLOWED_ORIGINS := splitList(os.Getenv("CORS_ALLOWED_ORIGINS"))        // Orígenes separados por comas
+	TRUSTED_PROXIES := splitList(os.Getenv("TRUSTED_PROXIES"))                  // IPs o redes CIDR separadas por comas
+	MAIL_DRIVER := os.Getenv("MAIL_DRIVER")
+	MAIL_FROM := os.Getenv("MAIL_FROM")
+	MAIL_FILE := os.Getenv("MAIL_FILE")
+	SMTP_HOST := os.Getenv("SMTP_HOST")
+	SMTP_PORT := os.Getenv("SMTP_PORT")
+	SMTP_USERNAME := os.Getenv("SMTP_USERNAME")
+	SMTP_PASSWORD := os.Getenv("SMTP_PASSWORD")
+	PASSWORD_RESET_URL := os.Getenv("PASSWORD_RESET_URL")
+	PASSWORD_RESET_TTL, _ := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")) // Vacío o inválido: valor por defecto
+	EMAIL_VERIFICATION_URL := os.Getenv("EMAIL_VERIFICATION_URL")
+	EMAIL_VERIFICATION_TTL, _ := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL")) // Vacío o inválido: valor por defecto
+	REQUIRE_VERIFIED_EMAIL, _ := strconv.ParseBool(os.Getenv("REQUIRE_VERIFIED_EMAIL"))  // Vacío o inválido: false
+
+	// Subcomando: go run . migrate up | down [steps] | status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(DATABASE_URL, os.Args[2:]); err != nil {
+			fatal("error running migrations", err)
+		}
+		return
+	}
+
+	s, error := server.NewServer(context.Background(), &server.ServerConfig{
+		Port:           ":" + PORT,
+		JWTSecret:      JWT_SECRET,
+		DatabaseURL:    DATABASE_URL,
+		DatabaseDriver: DATABASE_DRIVER,
+		AutoMigrate:    DATABASE_AUTO_MIGRATE,
+
+		JWTAlgorithm: JWT_ALGORITHM,
+		JWTKeysDir:   JWT_KEYS_DIR,
+		JWTActiveKid: JWT_ACTIVE_KID,
+
+		JWTIssuer:   JWT_ISSUER,
+		JWTAudience: JWT_AUDIENCE,
+		JWTLeeway:   JWT_LEEWAY,
+
+		AccessTokenTTL:  ACCESS_TOKEN_TTL,
+		RefreshTokenTTL: REFRESH_TOKEN_TTL,
+
+		AuthCookieSecure:   AUTH_COOKIE_SECURE,
+		CORSAllowedOrigins: CORS_ALLOWED_ORIGINS,
+		TrustedProxies:     TRUSTED_PROXIES,
+
+		ShutdownTimeout:    SHUTDOWN_TIMEOUT,
+		ShutdownDrainDelay: SHUTDOWN_DRAIN_DELAY,
+
+		WSSendBufferSize:     WS_SEND_BUFFER,
+		WSSlowConsumerPolicy: WS_SLOW_CONSUMER_POLICY,
+
+		MailDriver:   MAIL_DRIVER,
+		MailFrom:     MAIL_FROM,
+		MailFile:     MAIL_FILE,
+		SMTPHost:     SMTP_HOST,
+		SMTPPort:     SMTP_PORT,
+		SMTPUsername: SMTP_USERNAME,
+		SMTPPassword: SMTP_PASSWORD,
+
+		PasswordResetURL: PASSWORD_RESET_URL,
+		PasswordResetTTL: PASSWORD_RESET_TTL,
+
+		EmailVerificationURL: EMAIL_VERIFICATION_URL,
+		EmailVerificationTTL: EMAIL_VERIFICATION_TTL,
+		RequireVerifiedEmail: REQUIRE_VERIFIED_EMAIL,
+	})
+	if error != nil {
+		fatal("error creating server", error)
+	}
+
+	// SIGINT (Ctrl+C) o SIGTERM (docker stop, Kubernetes) inician el apagado ordenado
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := s.Start(ctx, BindRoutes); err != nil {
+		fatal("server error", err)
+	}
+	slog.Info("server stopped")
+}
+
+func BindRoutes(s server.Server, r *mux.Router) {
+	// Middlewares de todas las rutas, incluidas las de los subrouters
+	r.Use(middlewares.RequestIDMiddleware) // Request ID en el contexto y en el header X-Request-ID
+	r.Use(middlewares.LoggingMiddleware)   // Una línea de log por request
+	r.Use(middlewares.MetricsMiddleware)   // Métricas de Prometheus
+
+	auth := middlewares.NewAuthPolicy()               // Rutas públicas (por método); el resto requiere token
+	api := r.PathPrefix("/api/v1").Subrouter()        // Subrouter para agrupar las rutas de la API
+	api.Use(middlewares.CheckAuthMiddleware(s, auth)) // Middleware de autenticación para todas las rutas de la API
+	api.Use(middlewares.RateLimit(300, time.Minute))  // Límite general por usuario autenticado (o por IP en las rutas públicas)
+
+	// Límites por ruta, más estrictos que el general (cada uno lleva sus propios contadores)
+	signupLimit := middlewares.RateLimit(10, time.Hour)       // Creación de cuentas por IP
+	loginLimit := middlewares.RateLimit(20, time.Minute)      // Complementa la protección de fuerza bruta del login
+	refreshLimit := middlewares.RateLimit(30, time.Minute)    // Renovación de tokens
+	forgotLimit := middlewares.RateLimit(5, time.Hour)        // Emails de restablecimiento de contraseña por IP
+	resetLimit := middlewares.RateLimit(10, time.Hour)        // Intentos de restablecer la contraseña por IP
+	verifyLimit := middlewares.RateLimit(10, time.Hour)       // Intentos de verificar un email por IP
+	resendLimit := middlewares.RateLimit(3, time.Hour)        // Reenvíos del email de verificación por usuario
+	verified := middlewares.RequireVerifiedEmail(s)           // Política de email verificado (REQUIRE_VERIFIED_EMAIL)
+	postCreateLimit := middlewares.RateLimit(10, time.Minute) // Cada post creado se difunde a todos los clientes WebSocket
+	postWriteLimit := middlewares.RateLimit(60, time.Minute)  // Ediciones y borrados de posts
+	wsLimit := middlewares.RateLimit(30, time.Minute)         // Handshakes WebSocket por IP
+
+	// 1. Endpoints
+	api.HandleFunc("/", handlers.HomeHandler(s)).Methods(http.MethodGet)
+	auth.Public(api.Handle("/signup", signupLimit(middlewares.ValidateBody[handlers.SignupRequest](handlers.SingUpHandler(s)))).Methods(http.MethodPost))
+	auth.Public(api.Handle("/login", loginLimit(middlewares.ValidateBody[handlers.LoginRequest](handlers.LoginHandler(s)))).Methods(http.MethodPost))
+	auth.Public(api.Handle("/token/refresh", refreshLimit(handlers.RefreshTokenHandler(s))).Methods(http.MethodPost))
+	auth.Public(api.Handle("/password/forgot", forgotLimit(middlewares.ValidateBody[handlers.ForgotPasswordRequest](handlers.ForgotPasswordHandler(s)))).Methods(http.MethodPost))
+	auth.Public(api.Handle("/password/reset", resetLimit(middlewares.ValidateBody[handlers.ResetPasswordRequest](handlers.ResetPasswordHandler(s)))).Methods(http.MethodPost))
+	auth.Public(api.Handle("/email/verify", verifyLimit(middlewares.ValidateBody[handlers.VerifyEmailRequest](handlers.VerifyEmailHandler(s)))).Methods(http.MethodPost))
+	api.Handle("/email/verify/resend", resendLimit(handlers.ResendVerificationHandler(s))).Methods(http.MethodPost)
+	api.HandleFunc("/logout", handlers.LogoutHandler(s)).Methods(http.MethodPost)
+	api.HandleFunc("/user-info", handlers.GetUserFromTokenHandler(s)).Methods(http.MethodGet)
+
+	// Lecturas públicas, escrituras autenticadas
+	auth.Public(api.HandleFunc("/posts/{id:[0-9]+}", handlers.GetPostByIdHandler(s)).Methods(http.MethodGet))
+	api.Handle("/posts/{id:[0-9]+}", postWriteLimit(middlewares.ValidateBody[handlers.UpsertPostRequest](handlers.UpdatePostHandler(s)))).Methods(http.MethodPut)
+	api.Handle("/posts/{id:[0-9]+}", postWriteLimit(handlers.DeletePostHandler(s))).Methods(http.MethodDelete)
+	api.Handle("/posts", postCreateLimit(verified(middlewares.ValidateBody[handlers.UpsertPostRequest](handlers.CreatePostHandler(s))))).Methods(http.MethodPost)
+	auth.Public(api.HandleFunc("/posts", handlers.GetAllPostsHandler(s)).Methods(http.MethodGet))
+
+	// Administración de usuarios (solo administradores)
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middlewares.RequireRoles(models.RoleAdmin))
+	admin.HandleFunc("/users", handlers.ListUsersHandler(s)).Methods(http.MethodGet)
+	admin.HandleFunc("/users/{id:[0-9]+}/role", handlers.UpdateUserRoleHandler(s)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id:[0-9]+}", handlers.DeleteUserHandler(s)).Methods(http.MethodDelete)
+	admin.HandleFunc("/users/{id:[0-9]+}/unlock", handlers.UnlockUserHandler(s)).Methods(http.MethodPost)
+
+	// Sondas para el orquestador (públicas, fuera de /api/v1)
+	r.HandleFunc("/healthz", handlers.HealthzHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", handlers.ReadyzHandler(s)).Methods(http.MethodGet)
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
+	// Claves públicas para verificar los access tokens desde otros servicios
+	r.HandleFunc("/.well-known/jwks.json", handlers.JWKSHandler(s)).Methods(http.MethodGet)
+
+	// 2. WebSocket
+	// Los clientes WebSocket se autentican con el mismo JWT y las mismas reglas que la API REST
+	s.Hub().SetAuthenticator(middlewares.WebSocketAuthenticator(s))
+	r.Handle("/ws", wsLimit(http.HandlerFunc(s.Hub().WebSocketHandler)))
+}
+
+// fatal registra un error que impide iniciar o mantener el servidor y termina el proceso
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
+}
+
+// splitList separa una variable de entorno con valores separados por comas
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,156 @@
+// Package metrics expone las métricas de Prometheus de la API
+// Agrupa las métricas de HTTP, base de datos y WebSockets en un registro propio,
+// publicado por Handler en /metrics
+package metrics
+
+import (
+	"afperdomo2/go/rest-ws/websockets"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry contiene todas las métricas de la API, además de las del runtime de Go y del proceso
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route template.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency, by query (statement and table).",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+
+	dbQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Database queries that failed, by query (statement and table).",
+	}, []string{"query"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		dbQueryDuration,
+		dbQueryErrors,
+	)
+}
+
+// Handler publica las métricas en el formato de exposición de Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest registra una request HTTP atendida
+// route es el template de la ruta (ej: /api/v1/posts/{id:[0-9]+}), nunca la URL concreta,
+// para que el número de series no crezca con los IDs
+func ObserveHTTPRequest(method string, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveDBQuery registra la latencia de una consulta y, si falló, el error
+func ObserveDBQuery(query string, duration time.Duration, err error) {
+	dbQueryDuration.WithLabelValues(query).Observe(duration.Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(query).Inc()
+	}
+}
+
+// replaceableCollectors guarda los collectors de RegisterDBStats y RegisterHub por nombre,
+// para reemplazarlos si se vuelven a registrar
+var (
+	replaceableMutex      sync.Mutex
+	replaceableCollectors = make(map[string]prometheus.Collector)
+)
+
+// replaceCollector registra el collector bajo ese nombre, retirando antes el registrado previamente
+// Server.Setup puede ejecutarse varias veces en el mismo proceso (p. ej. varios servidores httptest);
+// las métricas publicadas son siempre las del último pool o Hub registrado
+func replaceCollector(name string, collector prometheus.Collector) error {
+	replaceableMutex.Lock()
+	defer replaceableMutex.Unlock()
+
+	if previous, ok := replaceableCollectors[name]; ok {
+		registry.Unregister(previous)
+	}
+	if err := registry.Register(collector); err != nil {
+		delete(replaceableCollectors, name)
+		return err
+	}
+	replaceableCollectors[name] = collector
+	return nil
+}
+
+// RegisterDBStats publica las estadísticas del pool de conexiones (go_sql_*)
+// Volver a registrar el mismo dbName reemplaza el pool anterior
+func RegisterDBStats(dbName string, db *sql.DB) error {
+	return replaceCollector("db:"+dbName, collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterHub publica las métricas de entrega del Hub de WebSockets
+// Volver a llamarla reemplaza el Hub anterior
+func RegisterHub(hub *websockets.Hub) error {
+	return replaceCollector("hub", &hubCollector{hub: hub})
+}
+
+// hubCollector lee las métricas del Hub (Hub.Stats) en cada scrape
+type hubCollector struct {
+	hub *websockets.Hub
+}
+
+var (
+	hubClientsDesc = prometheus.NewDesc(
+		"websocket_clients",
+		"WebSocket clients currently connected.",
+		nil, nil,
+	)
+	hubMessagesSentDesc = prometheus.NewDesc(
+		"websocket_messages_sent_total",
+		"WebSocket messages queued for delivery to a client.",
+		nil, nil,
+	)
+	hubMessagesDroppedDesc = prometheus.NewDesc(
+		"websocket_messages_dropped_total",
+		"WebSocket messages dropped because a client queue was full.",
+		nil, nil,
+	)
+	hubEvictedDesc = prometheus.NewDesc(
+		"websocket_slow_consumers_evicted_total",
+		"WebSocket clients disconnected for being too slow.",
+		nil, nil,
+	)
+)
+
+func (c *hubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hubClientsDesc
+	ch <- hubMessagesSentDesc
+	ch <- hubMessagesDroppedDesc
+	ch <- hubEvictedDesc
+}
+
+func (c *hubCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.hub.Stats()
+	ch <- prometheus.MustNewConstMetric(hubClientsDesc, prometheus.GaugeValue, float64(stats.Clients))
+	ch <- prometheus.MustNewConstMetric(hubMessagesSentDesc, prometheus.CounterValue, float64(stats.MessagesSent))
+	ch <- prometheus.MustNewConstMetric(hubMessagesDroppedDesc, prometheus.CounterValue, float64(stats.MessagesDropped))
+	ch <- prometheus.MustNewConstMetric(hubEvictedDesc, prometheus.CounterValue, float64(stats.SlowConsumersEvicted))
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"context"
+)
+
+// principalKey es la clave privada con la que se guarda el usuario autenticado en el contexto
+type principalKey struct{}
+
+// principal agrupa los claims del token validado y el usuario al que pertenecen
+type principal struct {
+	claims *models.AppClaims
+	user   *models.User
+}
+
+// WithPrincipal devuelve un contexto derivado que contiene los claims y el usuario autenticado
+// Lo usa CheckAuthMiddleware; también sirve para preparar requests en pruebas
+func WithPrincipal(ctx context.Context, claims *models.AppClaims, user *models.User) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal{claims: claims, user: user})
+}
+
+// UserFromContext devuelve el usuario autenticado de la request
+// El segundo valor es false si la ruta no pasó por CheckAuthMiddleware (p. ej. una ruta pública)
+func UserFromContext(ctx context.Context) (*models.User, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	if !ok || p.user == nil {
+		return nil, false
+	}
+	return p.user, true
+}
+
+// ClaimsFromContext devuelve los claims del token con el que se autenticó la request
+func ClaimsFromContext(ctx context.Context) (*models.AppClaims, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	if !ok || p.claims == nil {
+		return nil, false
+	}
+	return p.claims, true
+}
@@ -1,51 +1,67 @@
-package middlewares
-
-import (
-	"afperdomo2/go/rest-ws/server"
-	"net/http"
-	"slices"
-	"strings"
-
-	"github.com/golang-jwt/jwt"
-)
-
-var (
-	PUBLIC_ENDPOINTS = []string{
-		"/api/v1/signup",
-		"/api/v1/login",
-		"/api/v1/posts",
-	}
-)
-
-// Si la ruta no está en la lista de endpoints públicos, se requiere verificación
-func shouldCheckToken(route string) bool {
-	return !slices.Contains(PUBLIC_ENDPOINTS, route)
-}
-
-// CheckAuthMiddleware verifica el token JWT en las rutas protegidas
-// Si el token es válido, permite el acceso a la ruta; de lo contrario, retorna un error 401 Unauthorized
-// Este middleware se aplica a todas las rutas excepto a las que están en PUBLIC_ENDPOINTS
-func CheckAuthMiddleware(s server.Server) func(h http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Si la ruta no requiere verificación de token, se pasa directamente al siguiente handler
-			if !shouldCheckToken(r.URL.Path) {
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			// Si la ruta requiere verificación de token, se verifica el JWT
-			tokenString := strings.TrimSpace(r.Header.Get("Authorization"))
-			_, err := jwt.ParseWithClaims(tokenString, &jwt.StandardClaims{}, func(token *jwt.Token) (any, error) {
-				return []byte(s.Config().JWTSecret), nil
-			})
-			if err != nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-
-			// Si el token es válido, se pasa al siguiente handler
-			next.ServeHTTP(w, r)
-		})
-	}
-}
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/services"
+	"afperdomo2/go/rest-ws/utils"
+	"afperdomo2/go/rest-ws/websockets"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// CheckAuthMiddleware verifica el token JWT en las rutas protegidas
+// Si el token es válido, carga el usuario autenticado y lo guarda en el contexto de la request
+// (ver UserFromContext y ClaimsFromContext); de lo contrario, retorna un error 401 Unauthorized
+// con el desafío WWW-Authenticate del esquema Bearer
+// Este middleware se aplica a todas las rutas excepto a las declaradas públicas en la AuthPolicy
+func CheckAuthMiddleware(s server.Server, policy *AuthPolicy) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Si la ruta (y el método) es pública, se pasa directamente al siguiente handler
+			if policy.IsPublic(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Si la ruta requiere verificación de token, se verifica el JWT (firma, expiración y revocación)
+			// El token se toma del header "Authorization: Bearer <token>" o de la cookie HttpOnly
+			tokenString, err := utils.ExtractTokenFromRequest(r)
+			if err != nil {
+				utils.WriteUnauthorized(w, err)
+				return
+			}
+			claims, user, err := services.UserServiceInstance.Authenticate(r.Context(), tokenString, s.TokenOptions())
+			if errors.Is(err, services.ErrUnauthenticated) {
+				utils.WriteUnauthorized(w, err)
+				return
+			}
+			if err != nil {
+				utils.WriteDomainError(w, r, err)
+				return
+			}
+
+			// Si el token es válido, se pasa al siguiente handler con el usuario en el contexto
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), claims, user)))
+		})
+	}
+}
+
+// WebSocketAuthenticator valida los tokens de los clientes WebSocket con las mismas reglas que
+// CheckAuthMiddleware: el usuario debe existir y el token no puede ser anterior a su último
+// cambio de contraseña. Los errores internos se registran en el log y el cliente solo recibe
+// ErrUnauthenticated, porque el Hub reenvía el mensaje del error por el socket
+func WebSocketAuthenticator(s server.Server) websockets.Authenticator {
+	return func(ctx context.Context, token string) (int64, error) {
+		claims, _, err := services.UserServiceInstance.Authenticate(ctx, token, s.TokenOptions())
+		if err != nil && !errors.Is(err, services.ErrUnauthenticated) {
+			slog.ErrorContext(ctx, "websocket authentication failed", "error", err)
+			return 0, services.ErrUnauthenticated
+		}
+		if err != nil {
+			return 0, err
+		}
+		return claims.UserId, nil
+	}
+}
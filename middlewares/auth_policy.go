@@ -0,0 +1,94 @@
+package middlewares
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// AuthPolicy declara qué rutas de la API son públicas (no requieren token), por método HTTP
+// Todas las rutas que no coincidan con ninguna regla requieren autenticación
+type AuthPolicy struct {
+	mutex sync.RWMutex
+	rules []publicRule
+}
+
+// publicRule es una regla de acceso público: un patrón de ruta y los métodos a los que aplica
+type publicRule struct {
+	pattern string   // Plantilla de gorilla/mux o patrón con comodines
+	methods []string // Métodos HTTP; vacío significa todos
+}
+
+// NewAuthPolicy crea una política sin rutas públicas
+func NewAuthPolicy() *AuthPolicy {
+	return &AuthPolicy{}
+}
+
+// Public marca como pública una ruta ya registrada, con su misma plantilla y sus mismos métodos
+// Está pensado para usarse al registrar la ruta en BindRoutes:
+//
+//	auth.Public(api.HandleFunc("/posts", handler).Methods(http.MethodGet))
+func (p *AuthPolicy) Public(route *mux.Route) *mux.Route {
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return route
+	}
+	methods, _ := route.GetMethods() // Sin .Methods(...) la regla aplica a todos los métodos
+	p.Allow(template, methods...)
+	return route
+}
+
+// Allow declara público un patrón de ruta para los métodos indicados (todos si no se indica ninguno)
+// El patrón puede ser:
+//   - La plantilla de la ruta en gorilla/mux, ej: "/api/v1/posts/{id:[0-9]+}"
+//   - Una ruta con comodines: "*" coincide con un segmento (admite globs como "v*")
+//     y "**" al final coincide con cualquier sufijo, ej: "/api/v1/posts/**"
+func (p *AuthPolicy) Allow(pattern string, methods ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.rules = append(p.rules, publicRule{pattern: pattern, methods: methods})
+}
+
+// IsPublic indica si la petición coincide con alguna regla pública
+func (p *AuthPolicy) IsPublic(r *http.Request) bool {
+	template := ""
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ = route.GetPathTemplate()
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, rule := range p.rules {
+		if len(rule.methods) > 0 && !slices.Contains(rule.methods, r.Method) {
+			continue
+		}
+		if rule.pattern == template || matchPath(rule.pattern, r.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath compara una ruta con un patrón segmento a segmento
+func matchPath(pattern string, requestPath string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(requestPath, "/"), "/")
+
+	for i, segment := range patternSegments {
+		// "**" como último segmento acepta cualquier sufijo (incluso vacío)
+		if segment == "**" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if matched, err := path.Match(segment, pathSegments[i]); err != nil || !matched {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
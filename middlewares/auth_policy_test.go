@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// Coincidencia exacta
+		{"/api/v1/posts", "/api/v1/posts", true},
+		{"/api/v1/posts", "/api/v1/post", false},
+		{"/api/v1/posts", "/api/v1/posts/7", false},
+		{"/api/v1/posts/7", "/api/v1/posts", false},
+		{"/", "/", true},
+		{"/", "/api", false},
+		// Barra final: se ignora tanto en el patrón como en la ruta
+		{"/api/v1/posts", "/api/v1/posts/", true},
+		{"/api/v1/posts/", "/api/v1/posts", true},
+		{"/api/v1/posts", "/api/v1//posts", false},
+		// "*" coincide con exactamente un segmento, nunca con "/"
+		{"/api/v1/posts/*", "/api/v1/posts/7", true},
+		{"/api/v1/posts/*", "/api/v1/posts/7/comments", false},
+		{"/api/v1/posts/*", "/api/v1/posts", false},
+		{"/api/*/posts", "/api/v2/posts", true},
+		{"/api/v*/posts", "/api/v2/posts", true},
+		{"/api/v*/posts", "/api/beta/posts", false},
+		// "**" al final acepta cualquier sufijo, incluso vacío; en otra posición es un segmento literal
+		{"/api/v1/posts/**", "/api/v1/posts/7/comments", true},
+		{"/api/v1/posts/**", "/api/v1/posts", true},
+		{"/api/v1/posts/**", "/api/v1/users/7", false},
+		{"/api/**/posts", "/api/v1/posts", true},
+		{"/api/**/posts", "/api/v1/x/posts", false},
+		// Un patrón mal formado nunca coincide
+		{"/api/[v1/posts", "/api/[v1/posts", false},
+	}
+	for _, tc := range cases {
+		if got := matchPath(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestAuthPolicyIsPublic(t *testing.T) {
+	auth := NewAuthPolicy()
+	router := mux.NewRouter()
+	var public bool
+	handler := func(w http.ResponseWriter, r *http.Request) { public = auth.IsPublic(r) }
+
+	auth.Public(router.HandleFunc("/api/v1/posts/{id:[0-9]+}", handler).Methods(http.MethodGet))
+	router.HandleFunc("/api/v1/posts/{id:[0-9]+}", handler).Methods(http.MethodPut, http.MethodDelete)
+	auth.Public(router.HandleFunc("/api/v1/login", handler).Methods(http.MethodPost))
+	auth.Public(router.HandleFunc("/healthz", handler)) // Sin .Methods: pública para todos
+	router.HandleFunc("/api/v1/docs/{page}", handler)
+	router.HandleFunc("/api/v1/admin/users", handler)
+	auth.Allow("/api/v1/docs/*", http.MethodGet)
+
+	cases := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{http.MethodGet, "/api/v1/posts/7", true}, // Por la plantilla de mux
+		{http.MethodPut, "/api/v1/posts/7", false},
+		{http.MethodDelete, "/api/v1/posts/7", false},
+		{http.MethodPost, "/api/v1/login", true},
+		{http.MethodGet, "/healthz", true},
+		{http.MethodHead, "/healthz", true},
+		{http.MethodGet, "/api/v1/docs/intro", true}, // Por el patrón con comodín
+		{http.MethodPost, "/api/v1/docs/intro", false},
+		{http.MethodGet, "/api/v1/admin/users", false},
+	}
+	for _, tc := range cases {
+		public = false
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status = %d, route not matched", tc.method, tc.path, rec.Code)
+		}
+		if public != tc.want {
+			t.Errorf("%s %s: IsPublic = %v, want %v", tc.method, tc.path, public, tc.want)
+		}
+	}
+
+	// Sin ruta de mux (p. ej. un 404), solo cuentan los patrones sobre la ruta de la URL
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/posts/7", nil)
+	if auth.IsPublic(r) {
+		t.Error("IsPublic matched a mux template against a request without a current route")
+	}
+	if r := httptest.NewRequest(http.MethodGet, "/api/v1/docs/intro/", nil); !auth.IsPublic(r) {
+		t.Error("IsPublic did not match a wildcard pattern outside the router")
+	}
+}
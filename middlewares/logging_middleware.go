@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/logging"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// LoggingMiddleware registra una línea por request con su ruta, código de estado y duración
+// Solo se registra la ruta sin query string, que puede llevar el access token (?token=)
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(r.Context(), level, "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", recorder.status,
+			"duration_ms", logging.Milliseconds(time.Since(start)),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
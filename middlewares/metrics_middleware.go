@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/metrics"
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MetricsMiddleware registra el número de requests, su código de estado y su latencia,
+// etiquetados con el template de la ruta de gorilla/mux (ej: /api/v1/posts/{id:[0-9]+})
+// Debe registrarse en el router raíz para medir también las rutas de los subrouters
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		metrics.ObserveHTTPRequest(r.Method, route, recorder.status, time.Since(start))
+	})
+}
+
+// statusRecorder captura el código de estado que escribe el handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Hijack permite el upgrade a WebSocket a través del recorder (la conexión queda con 101)
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		sr.status = http.StatusSwitchingProtocols
+		sr.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap expone el ResponseWriter original a http.ResponseController
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
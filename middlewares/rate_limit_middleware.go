@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/utils"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers de cuota (borrador IETF "RateLimit header fields for HTTP")
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"     // Requests permitidas por ventana
+	RateLimitRemainingHeader = "RateLimit-Remaining" // Requests que quedan sin esperar
+	RateLimitResetHeader     = "RateLimit-Reset"     // Segundos hasta recuperar la cuota completa
+	RateLimitPolicyHeader    = "RateLimit-Policy"    // Política aplicada, ej: 30;w=60
+)
+
+// RateLimit limita cada cliente a limit requests por window con un token bucket en memoria
+// Atajo de RateLimitMiddleware(utils.NewTokenBucketLimiter(limit, window))
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	return RateLimitMiddleware(utils.NewTokenBucketLimiter(limit, window))
+}
+
+// RateLimitMiddleware limita las requests de cada cliente con el limitador indicado
+// El cliente es el usuario autenticado si la request pasó por CheckAuthMiddleware, o su IP si no
+// (ver utils.ClientIP para el tratamiento de X-Forwarded-For)
+// Añade los headers RateLimit-* a la respuesta y, si se supera el límite, responde 429 con Retry-After
+//
+// Los límites se pueden anidar (uno general para el subrouter y otro más estricto para una ruta):
+// los headers reflejan el límite al que le quedan menos requests
+func RateLimitMiddleware(limiter utils.RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := rateLimitKey(r)
+			result := limiter.Allow(key)
+			writeRateLimitHeaders(w, result)
+
+			if !result.Allowed {
+				slog.WarnContext(r.Context(), "rate limit exceeded",
+					"key", key,
+					"path", r.URL.Path,
+					"retry_after_ms", result.RetryAfter.Milliseconds(),
+				)
+				utils.WriteTooManyRequests(w, result.RetryAfter, "Too many requests, please try again later")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifica al cliente: "user:<id>" si está autenticado o "ip:<ip>" si no
+func rateLimitKey(r *http.Request) string {
+	if user, ok := UserFromContext(r.Context()); ok {
+		return "user:" + strconv.FormatInt(user.Id, 10)
+	}
+	return "ip:" + utils.ClientIP(r)
+}
+
+// writeRateLimitHeaders escribe los headers de cuota, salvo que un límite externo
+// ya haya escrito unos con menos requests restantes
+func writeRateLimitHeaders(w http.ResponseWriter, result utils.RateLimitResult) {
+	header := w.Header()
+	if current, err := strconv.Atoi(header.Get(RateLimitRemainingHeader)); err == nil && current < result.Remaining {
+		return
+	}
+	header.Set(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+	header.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+	header.Set(RateLimitResetHeader, strconv.FormatInt(ceilSeconds(result.Reset), 10))
+	header.Set(RateLimitPolicyHeader, strconv.Itoa(result.Limit)+";w="+strconv.FormatInt(ceilSeconds(result.Window), 10))
+}
+
+// ceilSeconds redondea una duración a segundos enteros hacia arriba
+func ceilSeconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
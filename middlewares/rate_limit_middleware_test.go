@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := RateLimit(2, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	steps := []struct {
+		status    int
+		remaining string
+		reset     string
+	}{
+		{http.StatusNoContent, "1", "30"},
+		{http.StatusNoContent, "0", "60"},
+		{http.StatusTooManyRequests, "0", "60"},
+	}
+	for i, step := range steps {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != step.status {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, step.status)
+		}
+		headers := map[string]string{
+			RateLimitLimitHeader:     "2",
+			RateLimitRemainingHeader: step.remaining,
+			RateLimitResetHeader:     step.reset,
+			RateLimitPolicyHeader:    "2;w=60",
+		}
+		if step.status == http.StatusTooManyRequests {
+			headers["Retry-After"] = "30"
+		}
+		for name, want := range headers {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("request %d: %s = %q, want %q", i+1, name, got, want)
+			}
+		}
+		if step.status != http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "" {
+			t.Errorf("request %d: unexpected Retry-After on an allowed request", i+1)
+		}
+	}
+}
+
+// recordingLimiter guarda las claves recibidas y deja pasar todas las requests
+type recordingLimiter struct {
+	keys []string
+}
+
+func (l *recordingLimiter) Allow(key string) utils.RateLimitResult {
+	l.keys = append(l.keys, key)
+	return utils.RateLimitResult{Allowed: true, Limit: 1, Window: time.Second, Remaining: 1}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	cases := []struct {
+		name string
+		user *models.User
+		want string
+	}{
+		{"anonymous by ip", nil, "ip:192.0.2.7"},
+		{"authenticated by user", &models.User{Id: 42}, "user:42"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := &recordingLimiter{}
+			handler := RateLimitMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.7:5555"
+			if tc.user != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), &models.AppClaims{UserId: tc.user.Id}, tc.user))
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if len(limiter.keys) != 1 || limiter.keys[0] != tc.want {
+				t.Fatalf("keys = %v, want [%s]", limiter.keys, tc.want)
+			}
+		})
+	}
+}
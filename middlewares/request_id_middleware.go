@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/logging"
+	"afperdomo2/go/rest-ws/utils"
+	"net/http"
+)
+
+// RequestIDHeader es el header con el que se recibe y se devuelve el request ID
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIdLength limita el tamaño de los request ID recibidos de los clientes
+const maxRequestIdLength = 128
+
+// RequestIDMiddleware asigna un request ID a cada request y lo guarda en el contexto,
+// de modo que todas las líneas de log registradas con r.Context() lo incluyan
+// Si el cliente (o el proxy) envía un X-Request-ID válido se reutiliza; si no, se genera uno
+// El request ID se devuelve siempre en el header X-Request-ID de la respuesta
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIDHeader)
+		if !isValidRequestId(requestId) {
+			generated, err := utils.GenerateRandomToken(12)
+			if err != nil {
+				utils.WriteError(w, http.StatusInternalServerError, "internal server error")
+				return
+			}
+			requestId = generated
+		}
+
+		w.Header().Set(RequestIDHeader, requestId)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestId)))
+	})
+}
+
+// isValidRequestId acepta IDs cortos con caracteres seguros para logs y headers (ej: UUIDs)
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, char := range requestId {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		case char == '-', char == '_', char == '.', char == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/utils"
+	"net/http"
+)
+
+// RequireRoles restringe las rutas a los usuarios autenticados que tengan alguno de los roles indicados
+// Se apoya en el usuario que CheckAuthMiddleware deja en el contexto, por lo que debe ejecutarse después
+// (p. ej. en un subrouter de la API). Se usa el rol actual del usuario y no el del token, así que un
+// cambio de rol se aplica de inmediato
+// Retorna 401 si la request no está autenticada y 403 si el rol del usuario no está permitido
+func RequireRoles(roles ...string) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				utils.WriteUnauthorized(w, nil)
+				return
+			}
+
+			if !user.HasRole(roles...) {
+				utils.WriteError(w, http.StatusForbidden, "Insufficient role")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
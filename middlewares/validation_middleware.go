@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// bodyKey es la clave privada con la que se guarda en el contexto el body ya validado
+type bodyKey struct{}
+
+// ValidateBody decodifica el body JSON de la request en un T y lo valida con las reglas
+// de su etiqueta validate (ver utils.Validate) antes de llamar al handler
+//   - Body que no es JSON válido: 400 (Bad Request)
+//   - Campos inválidos: 422 (Unprocessable Entity) con la lista de errores por campo
+//
+// El handler obtiene el body validado con BodyFromContext:
+//
+//	api.Handle("/signup", middlewares.ValidateBody[handlers.SignupRequest](handlers.SingUpHandler(s)))
+func ValidateBody[T any](next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := new(T)
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if fields := utils.Validate(body); len(fields) > 0 {
+			utils.WriteValidationError(w, fields)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), bodyKey{}, body)))
+	})
+}
+
+// BodyFromContext devuelve el body validado por ValidateBody
+// Retorna false si la ruta no usa ValidateBody con el mismo tipo T
+func BodyFromContext[T any](ctx context.Context) (*T, bool) {
+	body, ok := ctx.Value(bodyKey{}).(*T)
+	return body, ok
+}
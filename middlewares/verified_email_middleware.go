@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/utils"
+	"net/http"
+)
+
+// RequireVerifiedEmail restringe la ruta a los usuarios que confirmaron su email,
+// si la política RequireVerifiedEmail de la configuración está activa (si no, no hace nada)
+// Igual que RequireRoles, debe ejecutarse después de CheckAuthMiddleware y usa el estado actual
+// del usuario, así que la verificación se aplica de inmediato, sin renovar el token
+// Retorna 401 si la request no está autenticada y 403 si el email no está verificado
+func RequireVerifiedEmail(s server.Server) func(h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.Config().RequireVerifiedEmail {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				utils.WriteUnauthorized(w, nil)
+				return
+			}
+			if !user.IsVerified() {
+				utils.WriteError(w, http.StatusForbidden, "Email address not verified")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
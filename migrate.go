@@ -0,0 +1,85 @@
+package main
+
+import (
+	"afperdomo2/go/rest-ws/database"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrate ejecuta el subcomando migrate sobre la base de datos de DATABASE_URL
+//
+//	go run . migrate up          aplica todas las migraciones pendientes
+//	go run . migrate down [n]    revierte las últimas n migraciones (por defecto 1)
+//	go run . migrate status      muestra las migraciones aplicadas y pendientes
+func runMigrate(databaseURL string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if databaseURL == "" {
+		return errors.New("DATABASE_URL must be specified")
+	}
+
+	repo, err := database.NewPostgresRepository(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	migrator, err := repo.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("✅ Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("↩️ Reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, entry := range status {
+			state, appliedAt := "pending", ""
+			if entry.AppliedAt != nil {
+				state, appliedAt = "applied", entry.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", entry.Version, entry.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -1,8 +1,11 @@
-package models
-
-import "github.com/golang-jwt/jwt"
-
-type AppClaims struct {
-	UserId int64 `json:"user_id"`
-	jwt.StandardClaims
-}
+package models
+
+import "github.com/golang-jwt/jwt/v5"
+
+// AppClaims son los claims de los access tokens emitidos por la API
+// Los claims registrados (RFC 7519) incluyen jti, iss, sub, aud, iat, nbf y exp
+type AppClaims struct {
+	UserId int64  `json:"user_id"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
@@ -1,6 +1,49 @@
-package models
-
-type WebSocketMessage struct {
-	Type    string `json:"type"`
-	Payload any    `json:"payload"`
-}
+package models
+
+// WebSocketSchemaVersion es la versión actual del esquema de eventos WebSocket
+// Se incrementa ante cualquier cambio incompatible en los payloads (ver docs/websocket-events.md)
+const WebSocketSchemaVersion = 1
+
+// Tipos de eventos que el servidor publica a través del WebSocket
+const (
+	MessageTypePostCreated = "post_created" // Payload: Post
+	MessageTypePostUpdated = "post_updated" // Payload: Post (con el contenido actualizado)
+	MessageTypePostDeleted = "post_deleted" // Payload: PostDeletedPayload
+
+	// Evento privado: solo lo reciben las conexiones del autor cuando otro usuario modifica su post
+	MessageTypePostModerated = "post_moderated" // Payload: PostModeratedPayload
+)
+
+// Acciones del evento post_moderated
+const (
+	PostActionUpdated = "updated"
+	PostActionDeleted = "deleted"
+)
+
+type WebSocketMessage struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	Payload any    `json:"payload"`
+}
+
+// PostDeletedPayload es el payload del evento post_deleted
+type PostDeletedPayload struct {
+	Id     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+// PostModeratedPayload es el payload del evento post_moderated
+type PostModeratedPayload struct {
+	Id          int64  `json:"id"`
+	Action      string `json:"action"`
+	ModeratorId int64  `json:"moderator_id"`
+}
+
+// NewWebSocketMessage crea un evento con la versión actual del esquema
+func NewWebSocketMessage(messageType string, payload any) WebSocketMessage {
+	return WebSocketMessage{
+		Type:    messageType,
+		Version: WebSocketSchemaVersion,
+		Payload: payload,
+	}
+}
@@ -1,13 +1,13 @@
-package models
-
-import (
-	"time"
-)
-
-type Post struct {
-	Id        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-}
+package models
+
+import (
+	"time"
+)
+
+type Post struct {
+	Id        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
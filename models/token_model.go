@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// RefreshToken representa un token de refresco persistido
+// Solo se almacena el hash SHA-256 del token, nunca su valor original
+type RefreshToken struct {
+	Id        int64      `json:"id"`
+	UserId    int64      `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IsActive indica si el token no ha sido revocado y aún no expira
+func (t *RefreshToken) IsActive(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// Propósitos de los tokens de un solo uso que se envían por email
+const (
+	TokenPurposePasswordReset     = "password_reset"     // Restablecer la contraseña olvidada
+	TokenPurposeEmailVerification = "email_verification" // Confirmar el email tras el registro
+)
+
+// UserToken es un token de un solo uso enviado al usuario (p. ej. para restablecer la contraseña
+// o para confirmar su email)
+// Igual que en RefreshToken, solo se almacena el hash SHA-256 del token
+type UserToken struct {
+	Id        int64      `json:"id"`
+	UserId    int64      `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IsActive indica si el token no se ha usado y aún no expira
+func (t *UserToken) IsActive(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
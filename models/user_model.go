@@ -1,7 +1,56 @@
-package models
-
-type User struct {
-	Id       int64  `json:"id"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
+package models
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// Roles disponibles para los usuarios
+const (
+	RoleUser      = "user"      // Rol por defecto: gestiona solo sus propios posts
+	RoleModerator = "moderator" // Puede editar y borrar cualquier post
+	RoleAdmin     = "admin"     // Puede además administrar usuarios
+)
+
+// Roles contiene todos los roles válidos
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+type User struct {
+	Id       int64  `json:"id"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+
+	// PasswordChangedAt es la fecha del último cambio de contraseña (nil si nunca cambió)
+	// Los access tokens emitidos antes de esa fecha dejan de ser válidos
+	PasswordChangedAt *time.Time `json:"-"`
+
+	// VerifiedAt es la fecha en que el usuario confirmó su email (nil si aún no lo confirmó)
+	VerifiedAt *time.Time `json:"verified_at"`
+}
+
+// IsValidRole indica si el rol es uno de los roles soportados
+func IsValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// HasRole indica si el usuario tiene alguno de los roles indicados
+func (u *User) HasRole(roles ...string) bool {
+	return slices.Contains(roles, u.Role)
+}
+
+// IsVerified indica si el usuario confirmó que el email le pertenece
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// LogValue representa al usuario en los logs sin su contraseña (ni su hash)
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("id", u.Id),
+		slog.String("email", u.Email),
+		slog.String("role", u.Role),
+		slog.Bool("verified", u.IsVerified()),
+	)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import "errors"
+
+// Categorías de errores de dominio
+// Se comparan con errors.Is, por ejemplo: errors.Is(err, repository.ErrNotFound)
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Error es un error de dominio: un mensaje legible para el cliente y su categoría (Kind)
+// Las implementaciones del repositorio lo usan para que los handlers no dependan de la base de datos
+type Error struct {
+	Kind    error  // Una de las categorías: ErrNotFound, ErrConflict, ErrForbidden o ErrValidation
+	Message string // Mensaje seguro para mostrar al cliente
+	Err     error  // Error original (opcional), no se expone al cliente
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is permite que errors.Is(err, ErrNotFound) reconozca la categoría del error
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap expone el error original para inspección y logs
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func Conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func Forbidden(message string) error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func Validation(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+// Errores concretos compartidos por todas las implementaciones del repositorio
+var (
+	ErrUserNotFound         = NotFound("user not found")
+	ErrPostNotFound         = NotFound("post not found")
+	ErrNotPostOwner         = Forbidden("you are not allowed to modify this post")
+	ErrEmailTaken           = Conflict("email already registered")
+	ErrRefreshTokenNotFound = NotFound("refresh token not found")
+	ErrRefreshTokenRevoked  = Conflict("refresh token already revoked")
+	ErrUserTokenNotFound    = NotFound("token not found, expired or already used")
+)
+
+// ErrNotConfigured indica que aún no se ha configurado ninguna implementación con SetRepository
+var ErrNotConfigured = errors.New("repository not configured")
@@ -1,67 +1,156 @@
-package repository
-
-import (
-	"afperdomo2/go/rest-ws/models"
-	"context"
-)
-
-type Repository interface {
-	CreateUser(ctx context.Context, user *models.User) error
-	GetUserById(ctx context.Context, id int64) (*models.User, error)
-	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
-
-	CreatePost(ctx context.Context, post *models.Post) error
-	UpdatePost(ctx context.Context, id int64, changes *models.Post) error
-	GetPostById(ctx context.Context, id int64) (*models.Post, error)
-	DeletePost(ctx context.Context, id int64, userId int64) error
-	GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error)
-
-	Close() error // Método para cerrar la conexión a la base de datos
-}
-
-var implementation Repository
-
-func SetRepository(repository Repository) {
-	implementation = repository
-}
-
-func Close() error {
-	if implementation != nil {
-		return implementation.Close()
-	}
-	return nil
-}
-
-// User
-func CreateUser(ctx context.Context, user *models.User) error {
-	return implementation.CreateUser(ctx, user)
-}
-
-func GetUserById(ctx context.Context, id int64) (*models.User, error) {
-	return implementation.GetUserById(ctx, id)
-}
-
-func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	return implementation.GetUserByEmail(ctx, email)
-}
-
-// Post
-func CreatePost(ctx context.Context, post *models.Post) error {
-	return implementation.CreatePost(ctx, post)
-}
-
-func UpdatePost(ctx context.Context, id int64, changes *models.Post) error {
-	return implementation.UpdatePost(ctx, id, changes)
-}
-
-func GetPostById(ctx context.Context, id int64) (*models.Post, error) {
-	return implementation.GetPostById(ctx, id)
-}
-
-func DeletePost(ctx context.Context, id int64, userId int64) error {
-	return implementation.DeletePost(ctx, id, userId)
-}
-
-func GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error) {
-	return implementation.GetAllPosts(ctx, page, limit)
-}
+package repository
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"context"
+	"time"
+)
+
+type Repository interface {
+	CreateUser(ctx context.Context, user *models.User) error // Asigna el Id generado al usuario
+	GetUserById(ctx context.Context, id int64) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	ListUsers(ctx context.Context, page int64, limit int64) ([]*models.User, error)
+	UpdateUserRole(ctx context.Context, id int64, role string) error
+	UpdateUserPassword(ctx context.Context, id int64, passwordHash string) error // También registra password_changed_at
+	MarkUserVerified(ctx context.Context, id int64) error                        // Registra verified_at si aún no estaba verificado
+	DeleteUser(ctx context.Context, id int64) error
+
+	CreatePost(ctx context.Context, post *models.Post) error
+	UpdatePost(ctx context.Context, id int64, changes *models.Post, userId int64, anyOwner bool) (*models.Post, error) // Ver PostWriteFilter
+	GetPostById(ctx context.Context, id int64) (*models.Post, error)
+	DeletePost(ctx context.Context, id int64, userId int64, anyOwner bool) (*models.Post, error) // Ver PostWriteFilter
+	GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error)
+
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id int64) error
+	RevokeUserRefreshTokens(ctx context.Context, userId int64) error
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+
+	CreateUserToken(ctx context.Context, token *models.UserToken) error
+	ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (*models.UserToken, error) // Marca el token como usado si está activo
+	DeleteUserTokens(ctx context.Context, userId int64, purpose string) error
+
+	Ping(ctx context.Context) error // Verifica que la base de datos responde (usado por /readyz)
+	Close() error                   // Método para cerrar la conexión a la base de datos
+}
+
+var implementation Repository
+
+func SetRepository(repository Repository) {
+	implementation = repository
+}
+
+// Ping verifica que el repositorio está configurado y que la base de datos responde
+func Ping(ctx context.Context) error {
+	if implementation == nil {
+		return ErrNotConfigured
+	}
+	return implementation.Ping(ctx)
+}
+
+func Close() error {
+	if implementation != nil {
+		return implementation.Close()
+	}
+	return nil
+}
+
+// User
+func CreateUser(ctx context.Context, user *models.User) error {
+	return implementation.CreateUser(ctx, user)
+}
+
+func GetUserById(ctx context.Context, id int64) (*models.User, error) {
+	return implementation.GetUserById(ctx, id)
+}
+
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return implementation.GetUserByEmail(ctx, email)
+}
+
+func ListUsers(ctx context.Context, page int64, limit int64) ([]*models.User, error) {
+	return implementation.ListUsers(ctx, page, limit)
+}
+
+func UpdateUserRole(ctx context.Context, id int64, role string) error {
+	return implementation.UpdateUserRole(ctx, id, role)
+}
+
+func UpdateUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	return implementation.UpdateUserPassword(ctx, id, passwordHash)
+}
+
+func MarkUserVerified(ctx context.Context, id int64) error {
+	return implementation.MarkUserVerified(ctx, id)
+}
+
+func DeleteUser(ctx context.Context, id int64) error {
+	return implementation.DeleteUser(ctx, id)
+}
+
+// Post
+func CreatePost(ctx context.Context, post *models.Post) error {
+	return implementation.CreatePost(ctx, post)
+}
+
+// PostWriteFilter: UpdatePost y DeletePost solo modifican el post si pertenece a userId
+// o si anyOwner es true (moderadores y administradores). La comprobación y la escritura son
+// atómicas, así que el autor no puede cambiar (ni el post borrarse) entre una y otra
+// Retornan el post resultante (el borrado, en DeletePost), ErrPostNotFound si el post no existe
+// o ErrNotPostOwner si existe pero el usuario no puede modificarlo
+func UpdatePost(ctx context.Context, id int64, changes *models.Post, userId int64, anyOwner bool) (*models.Post, error) {
+	return implementation.UpdatePost(ctx, id, changes, userId, anyOwner)
+}
+
+func GetPostById(ctx context.Context, id int64) (*models.Post, error) {
+	return implementation.GetPostById(ctx, id)
+}
+
+func DeletePost(ctx context.Context, id int64, userId int64, anyOwner bool) (*models.Post, error) {
+	return implementation.DeletePost(ctx, id, userId, anyOwner)
+}
+
+func GetAllPosts(ctx context.Context, page int64, limit int64) ([]*models.Post, error) {
+	return implementation.GetAllPosts(ctx, page, limit)
+}
+
+// Tokens
+func CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return implementation.CreateRefreshToken(ctx, token)
+}
+
+func GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return implementation.GetRefreshTokenByHash(ctx, tokenHash)
+}
+
+func RevokeRefreshToken(ctx context.Context, id int64) error {
+	return implementation.RevokeRefreshToken(ctx, id)
+}
+
+func RevokeUserRefreshTokens(ctx context.Context, userId int64) error {
+	return implementation.RevokeUserRefreshTokens(ctx, userId)
+}
+
+func RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return implementation.RevokeToken(ctx, jti, expiresAt)
+}
+
+func IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return implementation.IsTokenRevoked(ctx, jti)
+}
+
+// User tokens
+func CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	return implementation.CreateUserToken(ctx, token)
+}
+
+func ConsumeUserToken(ctx context.Context, purpose string, tokenHash string) (*models.UserToken, error) {
+	return implementation.ConsumeUserToken(ctx, purpose, tokenHash)
+}
+
+func DeleteUserTokens(ctx context.Context, userId int64, purpose string) error {
+	return implementation.DeleteUserTokens(ctx, userId, purpose)
+}
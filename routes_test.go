@@ -0,0 +1,581 @@
+package main
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+const testPassword = "Passw0rd!x"
+
+func TestMain(m *testing.M) {
+	// Los handlers registran cada request y error: en los tests solo ensucian la salida
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newTestServer crea un servidor con el repositorio en memoria y las rutas de BindRoutes
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	h, _ := newTestServerWithMail(t)
+	return h
+}
+
+// newTestServerWithMail es newTestServer, pero devuelve también el archivo al que se envían los emails
+func newTestServerWithMail(t *testing.T) (http.Handler, string) {
+	t.Helper()
+	mailFile := filepath.Join(t.TempDir(), "mail.log")
+	return newTestServerWithConfig(t, &server.ServerConfig{
+		Port:           ":0",
+		JWTSecret:      "test-secret",
+		DatabaseDriver: server.DriverMemory,
+		MailDriver:     server.MailDriverFile,
+		MailFile:       mailFile,
+	}), mailFile
+}
+
+// newTestServerWithConfig crea un servidor con la configuración indicada y las rutas de BindRoutes
+func newTestServerWithConfig(t *testing.T, config *server.ServerConfig) http.Handler {
+	t.Helper()
+	s, err := server.NewServer(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	router, err := s.Setup(BindRoutes)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Hub().Shutdown(context.Background()); err != nil {
+			t.Errorf("hub shutdown: %v", err)
+		}
+	})
+	return router
+}
+
+// clientIPs da a cada request una IP distinta para no agotar los límites por IP entre casos
+var clientIPs atomic.Int32
+
+// doJSON envía body como JSON con el token indicado (si no está vacío) y devuelve la respuesta grabada
+func doJSON(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = fmt.Sprintf("192.0.2.%d:1234", clientIPs.Add(1)%250+1)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// fieldCodes extrae "campo:código" de una respuesta 422 de validación
+func fieldCodes(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var response struct {
+		Error struct {
+			Code   string `json:"code"`
+			Fields []struct {
+				Field string `json:"field"`
+				Code  string `json:"code"`
+			} `json:"fields"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding validation error %q: %v", rec.Body.String(), err)
+	}
+	if response.Error.Code != "validation_failed" {
+		t.Fatalf("error code = %q, want validation_failed", response.Error.Code)
+	}
+	codes := make([]string, 0, len(response.Error.Fields))
+	for _, field := range response.Error.Fields {
+		codes = append(codes, field.Field+":"+field.Code)
+	}
+	return codes
+}
+
+type validationCase struct {
+	name   string
+	body   string
+	status int
+	fields []string // Errores esperados ("campo:código") si status es 422
+}
+
+// runValidationCases ejecuta cada caso y comprueba el status y, en los 422, los errores por campo
+func runValidationCases(t *testing.T, h http.Handler, method, path, token string, cases []validationCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := doJSON(t, h, method, path, token, tc.body)
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.status != http.StatusUnprocessableEntity {
+				return
+			}
+			if got := strings.Join(fieldCodes(t, rec), ","); got != strings.Join(tc.fields, ",") {
+				t.Errorf("fields = [%s], want [%s]", got, strings.Join(tc.fields, ","))
+			}
+		})
+	}
+}
+
+// login registra email (si no existe) e inicia sesión; devuelve el access token
+func login(t *testing.T, h http.Handler, email string) string {
+	t.Helper()
+	credentials := fmt.Sprintf(`{"email":%q,"password":%q}`, email, testPassword)
+	doJSON(t, h, http.MethodPost, "/api/v1/signup", "", credentials)
+	rec := doJSON(t, h, http.MethodPost, "/api/v1/login", "", credentials)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login status = %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || response.Token == "" {
+		t.Fatalf("login response without token: %s", rec.Body.String())
+	}
+	return response.Token
+}
+
+// userByEmail lee el usuario directamente del repositorio
+func userByEmail(t *testing.T, email string) *models.User {
+	t.Helper()
+	user, err := repository.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail(%s): %v", email, err)
+	}
+	return user
+}
+
+func TestSignupValidation(t *testing.T) {
+	h := newTestServer(t)
+	long := strings.Repeat("a", 95) + "@x.io"
+
+	runValidationCases(t, h, http.MethodPost, "/api/v1/signup", "", []validationCase{
+		{"valid", `{"email":"ana@x.io","password":"Passw0rd!x"}`, http.StatusCreated, nil},
+		{"duplicate email", `{"email":"ana@x.io","password":"Passw0rd!x"}`, http.StatusConflict, nil},
+		{"malformed json", `{"email":`, http.StatusBadRequest, nil},
+		{"empty body", `{}`, http.StatusUnprocessableEntity, []string{"email:required", "password:required"}},
+		{"blank email", `{"email":"   ","password":"Passw0rd!x"}`, http.StatusUnprocessableEntity, []string{"email:required"}},
+		{"invalid email", `{"email":"ana","password":"Passw0rd!x"}`, http.StatusUnprocessableEntity, []string{"email:email"}},
+		{"email with name", `{"email":"Ana <ana@x.io>","password":"Passw0rd!x"}`, http.StatusUnprocessableEntity, []string{"email:email"}},
+		{"email too long", `{"email":"` + long + `x","password":"Passw0rd!x"}`, http.StatusUnprocessableEntity, []string{"email:max"}},
+		{"short password", `{"email":"bob@x.io","password":"Pa0!"}`, http.StatusUnprocessableEntity, []string{"password:password"}},
+		{"weak password", `{"email":"bob@x.io","password":"password"}`, http.StatusUnprocessableEntity, []string{"password:password"}},
+	})
+}
+
+func TestLoginValidation(t *testing.T) {
+	h := newTestServer(t)
+	login(t, h, "ana@x.io")
+
+	runValidationCases(t, h, http.MethodPost, "/api/v1/login", "", []validationCase{
+		{"valid", `{"email":"ana@x.io","password":"Passw0rd!x"}`, http.StatusOK, nil},
+		{"wrong password", `{"email":"ana@x.io","password":"Wr0ngPass!"}`, http.StatusUnauthorized, nil},
+		{"malformed json", `[]`, http.StatusBadRequest, nil},
+		{"missing password", `{"email":"ana@x.io"}`, http.StatusUnprocessableEntity, []string{"password:required"}},
+		{"invalid email", `{"email":"ana@","password":"Passw0rd!x"}`, http.StatusUnprocessableEntity, []string{"email:email"}},
+		{"password too long", `{"email":"ana@x.io","password":"` + strings.Repeat("a", 73) + `"}`, http.StatusUnprocessableEntity, []string{"password:max"}},
+	})
+}
+
+func TestPostValidation(t *testing.T) {
+	h := newTestServer(t)
+	token := login(t, h, "ana@x.io")
+
+	cases := []validationCase{
+		{"valid", `{"title":"Hola","content":"Primer post"}`, http.StatusCreated, nil},
+		{"malformed json", `{"title":1}`, http.StatusBadRequest, nil},
+		{"empty body", `{}`, http.StatusUnprocessableEntity, []string{"title:required", "content:required"}},
+		{"blank content", `{"title":"Hola","content":"  \n "}`, http.StatusUnprocessableEntity, []string{"content:required"}},
+		{"title too long", `{"title":"` + strings.Repeat("ñ", 256) + `","content":"x"}`, http.StatusUnprocessableEntity, []string{"title:max"}},
+		{"title at limit", `{"title":"` + strings.Repeat("ñ", 255) + `","content":"x"}`, http.StatusCreated, nil},
+	}
+	t.Run("create", func(t *testing.T) {
+		runValidationCases(t, h, http.MethodPost, "/api/v1/posts", token, cases)
+	})
+
+	// La actualización usa el mismo body: el post 1 es el creado por el caso "valid"
+	cases[0].status = http.StatusOK
+	cases[len(cases)-1].status = http.StatusOK
+	t.Run("update", func(t *testing.T) {
+		runValidationCases(t, h, http.MethodPut, "/api/v1/posts/1", token, cases)
+	})
+
+	// La autenticación se comprueba antes que el body
+	t.Run("unauthenticated", func(t *testing.T) {
+		rec := doJSON(t, h, http.MethodPost, "/api/v1/posts", "", `{}`)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestWebSocketAuthentication(t *testing.T) {
+	h := newTestServer(t)
+	token := login(t, h, "ana@x.io")
+
+	handshake := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Con un token válido se pasa la autenticación (el upgrade falla: httptest no es un WebSocket)
+	if status := handshake(); status == http.StatusUnauthorized {
+		t.Fatalf("handshake with a valid token: status = %d", status)
+	}
+
+	// El token sigue siendo válido criptográficamente, pero su usuario ya no existe
+	user := userByEmail(t, "ana@x.io")
+	if err := repository.DeleteUser(context.Background(), user.Id); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if status := handshake(); status != http.StatusUnauthorized {
+		t.Fatalf("handshake for a deleted user: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	h, mailFile := newTestServerWithMail(t)
+	token := login(t, h, "ana@x.io")
+
+	rec := doJSON(t, h, http.MethodPost, "/api/v1/email/verify/resend", token, "")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("resend status = %d, want %d (body: %s)", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+
+	// Los emails se envían en segundo plano: el del registro y el reenviado
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		content, _ := os.ReadFile(mailFile)
+		if strings.Count(string(content), "Subject: Confirm your email address") == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("verification emails not sent:\n%s", content)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	user := userByEmail(t, "ana@x.io")
+	if err := repository.MarkUserVerified(context.Background(), user.Id); err != nil {
+		t.Fatalf("MarkUserVerified: %v", err)
+	}
+	rec = doJSON(t, h, http.MethodPost, "/api/v1/email/verify/resend", token, "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("resend for a verified email: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestPostOwnership(t *testing.T) {
+	h := newTestServer(t)
+	owner := login(t, h, "ana@x.io")
+	other := login(t, h, "bob@x.io")
+	moderator := login(t, h, "mod@x.io")
+	if err := repository.UpdateUserRole(context.Background(), userByEmail(t, "mod@x.io").Id, models.RoleModerator); err != nil {
+		t.Fatalf("UpdateUserRole: %v", err)
+	}
+
+	rec := doJSON(t, h, http.MethodPost, "/api/v1/posts", owner, `{"title":"Hola","content":"Primer post"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	path := fmt.Sprintf("/api/v1/posts/%d", post.Id)
+	missing := fmt.Sprintf("/api/v1/posts/%d", post.Id+100)
+	body := `{"title":"Editado","content":"Nuevo contenido"}`
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"owner updates", http.MethodPut, path, owner, http.StatusOK},
+		{"other user updates", http.MethodPut, path, other, http.StatusForbidden},
+		{"other user deletes", http.MethodDelete, path, other, http.StatusForbidden},
+		{"moderator updates", http.MethodPut, path, moderator, http.StatusOK},
+		{"update missing post", http.MethodPut, missing, owner, http.StatusNotFound},
+		{"delete missing post", http.MethodDelete, missing, moderator, http.StatusNotFound},
+		{"moderator deletes", http.MethodDelete, path, moderator, http.StatusOK},
+		{"owner updates deleted post", http.MethodPut, path, owner, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rec := doJSON(t, h, tc.method, tc.path, tc.token, body)
+		if rec.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d (body: %s)", tc.name, rec.Code, tc.status, rec.Body.String())
+		}
+	}
+}
+
+func TestUnauthorizedResponsesHaveChallenge(t *testing.T) {
+	h := newTestServer(t)
+	login(t, h, "ana@x.io")
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+	}{
+		{"wrong password", http.MethodPost, "/api/v1/login", "", `{"email":"ana@x.io","password":"Wr0ngPass!"}`},
+		{"unknown email", http.MethodPost, "/api/v1/login", "", `{"email":"bob@x.io","password":"Passw0rd!x"}`},
+		{"invalid refresh token", http.MethodPost, "/api/v1/token/refresh", "", `{"refresh_token":"nope"}`},
+		{"invalid access token", http.MethodGet, "/api/v1/user-info", "nope", ""},
+		{"missing access token", http.MethodGet, "/api/v1/user-info", "", ""},
+	}
+	for _, tc := range cases {
+		rec := doJSON(t, h, tc.method, tc.path, tc.token, tc.body)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, http.StatusUnauthorized)
+			continue
+		}
+		if challenge := rec.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, "Bearer ") {
+			t.Errorf("%s: WWW-Authenticate = %q, want a Bearer challenge", tc.name, challenge)
+		}
+	}
+}
+
+// tokenPair decodifica la respuesta de login o refresh
+func tokenPair(t *testing.T, rec *httptest.ResponseRecorder) (string, string) {
+	t.Helper()
+	var response struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || response.Token == "" || response.RefreshToken == "" {
+		t.Fatalf("response without token pair: %s", rec.Body.String())
+	}
+	return response.Token, response.RefreshToken
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	h := newTestServer(t)
+	credentials := fmt.Sprintf(`{"email":"ana@x.io","password":%q}`, testPassword)
+	doJSON(t, h, http.MethodPost, "/api/v1/signup", "", credentials)
+	rec := doJSON(t, h, http.MethodPost, "/api/v1/login", "", credentials)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login status = %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	_, oldRefresh := tokenPair(t, rec)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		return doJSON(t, h, http.MethodPost, "/api/v1/token/refresh", "", fmt.Sprintf(`{"refresh_token":%q}`, token))
+	}
+
+	// La rotación emite un par nuevo con el que se puede seguir operando
+	rec = refresh(oldRefresh)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d (body: %s)", rec.Code, rec.Body.String())
+	}
+	access, newRefresh := tokenPair(t, rec)
+	if newRefresh == oldRefresh {
+		t.Fatal("refresh returned the same refresh token")
+	}
+	if rec := doJSON(t, h, http.MethodGet, "/api/v1/user-info", access, ""); rec.Code != http.StatusOK {
+		t.Fatalf("user-info with the refreshed access token = %d", rec.Code)
+	}
+
+	// Reutilizar el token rotado se trata como robo: se rechaza y se revoca toda la familia
+	if rec := refresh(oldRefresh); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed refresh token status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := refresh(newRefresh); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token issued before the replay status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPostModeratedNotification(t *testing.T) {
+	h := newTestServer(t)
+	author := login(t, h, "ana@x.io")
+	moderator := login(t, h, "mod@x.io")
+	authorId := userByEmail(t, "ana@x.io").Id
+	moderatorId := userByEmail(t, "mod@x.io").Id
+	if err := repository.UpdateUserRole(context.Background(), moderatorId, models.RoleModerator); err != nil {
+		t.Fatalf("UpdateUserRole: %v", err)
+	}
+
+	rec := doJSON(t, h, http.MethodPost, "/api/v1/posts", author, `{"title":"Hola","content":"Primer post"}`)
+	var post models.Post
+	json.Unmarshal(rec.Body.Bytes(), &post)
+	path := fmt.Sprintf("/api/v1/posts/%d", post.Id)
+
+	// El autor se conecta sin suscribirse a ningún topic: solo recibe los eventos privados
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	header := http.Header{"Authorization": {"Bearer " + author}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", header)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// La respuesta a auth garantiza que el Hub ya registró la conexión
+	conn.WriteJSON(map[string]string{"type": "auth", "token": author})
+	var ack struct {
+		Type string `json:"type"`
+	}
+	if err := conn.ReadJSON(&ack); err != nil || ack.Type != "auth_ok" {
+		t.Fatalf("auth reply = %+v (%v), want auth_ok", ack, err)
+	}
+
+	// Los cambios del propio autor no generan aviso; el primero que llega es el del moderador
+	body := `{"title":"Editado","content":"Nuevo contenido"}`
+	if rec := doJSON(t, h, http.MethodPut, path, author, body); rec.Code != http.StatusOK {
+		t.Fatalf("author update status = %d", rec.Code)
+	}
+	for _, step := range []struct {
+		method string
+		action string
+	}{
+		{http.MethodPut, models.PostActionUpdated},
+		{http.MethodDelete, models.PostActionDeleted},
+	} {
+		if rec := doJSON(t, h, step.method, path, moderator, body); rec.Code != http.StatusOK {
+			t.Fatalf("moderator %s status = %d", step.method, rec.Code)
+		}
+		var event struct {
+			Type    string                      `json:"type"`
+			Payload models.PostModeratedPayload `json:"payload"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("reading %s notification: %v", step.action, err)
+		}
+		want := models.PostModeratedPayload{Id: post.Id, Action: step.action, ModeratorId: moderatorId}
+		if event.Type != models.MessageTypePostModerated || event.Payload != want {
+			t.Fatalf("notification to author %d = %+v, want %s %+v", authorId, event, models.MessageTypePostModerated, want)
+		}
+	}
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	h := newTestServerWithConfig(t, &server.ServerConfig{
+		Port:           ":0",
+		JWTSecret:      "test-secret", // Sigue registrado para verificar los tokens HS256 sin kid
+		JWTAlgorithm:   "RS256",
+		DatabaseDriver: server.DriverMemory,
+	})
+	token := login(t, h, "ana@x.io")
+
+	rec := doJSON(t, h, http.MethodGet, "/.well-known/jwks.json", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("jwks status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, leak := range []string{"test-secret", base64.RawURLEncoding.EncodeToString([]byte("test-secret")), `"oct"`, `"k"`} {
+		if strings.Contains(body, leak) {
+			t.Fatalf("jwks exposes %s: %s", leak, body)
+		}
+	}
+
+	// El documento publicado basta para verificar los tokens emitidos (la clave efímera RS256)
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+		} `json:"keys"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &jwks)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[0].Alg != "RS256" || jwks.Keys[0].Kid != parsed.Header["kid"] {
+		t.Fatalf("jwks = %s, want the RSA key with kid %v", body, parsed.Header["kid"])
+	}
+}
+
+func TestAdminRoleGating(t *testing.T) {
+	h := newTestServer(t)
+	user := login(t, h, "ana@x.io")
+	doomed := login(t, h, "bob@x.io")
+	admin := login(t, h, "root@x.io")
+	userId := userByEmail(t, "ana@x.io").Id
+	doomedId := userByEmail(t, "bob@x.io").Id
+	adminId := userByEmail(t, "root@x.io").Id
+	if err := repository.UpdateUserRole(context.Background(), adminId, models.RoleAdmin); err != nil {
+		t.Fatalf("UpdateUserRole: %v", err)
+	}
+
+	// Los tokens no llevan el rol vigente: cada request usa el rol actual del usuario,
+	// así que los cambios de rol se aplican a la request siguiente sin volver a iniciar sesión
+	rolePath := fmt.Sprintf("/api/v1/admin/users/%d/role", userId)
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"anonymous lists users", http.MethodGet, "/api/v1/admin/users", "", "", http.StatusUnauthorized},
+		{"user lists users", http.MethodGet, "/api/v1/admin/users", user, "", http.StatusForbidden},
+		{"user changes a role", http.MethodPut, rolePath, user, `{"role":"admin"}`, http.StatusForbidden},
+		{"admin lists users", http.MethodGet, "/api/v1/admin/users", admin, "", http.StatusOK},
+		{"admin promotes user", http.MethodPut, rolePath, admin, `{"role":"admin"}`, http.StatusOK},
+		{"promoted user lists users", http.MethodGet, "/api/v1/admin/users", user, "", http.StatusOK},
+		{"admin demotes user", http.MethodPut, rolePath, admin, `{"role":"moderator"}`, http.StatusOK},
+		{"demoted user lists users", http.MethodGet, "/api/v1/admin/users", user, "", http.StatusForbidden},
+		{"admin sets an invalid role", http.MethodPut, rolePath, admin, `{"role":"root"}`, http.StatusUnprocessableEntity},
+		{"admin changes own role", http.MethodPut, fmt.Sprintf("/api/v1/admin/users/%d/role", adminId), admin, `{"role":"user"}`, http.StatusForbidden},
+		{"admin changes a missing user", http.MethodPut, "/api/v1/admin/users/999/role", admin, `{"role":"user"}`, http.StatusNotFound},
+		{"admin deletes own account", http.MethodDelete, fmt.Sprintf("/api/v1/admin/users/%d", adminId), admin, "", http.StatusForbidden},
+		{"admin deletes user", http.MethodDelete, fmt.Sprintf("/api/v1/admin/users/%d", doomedId), admin, "", http.StatusOK},
+		{"deleted user", http.MethodGet, "/api/v1/user-info", doomed, "", http.StatusUnauthorized},
+	}
+	for _, step := range steps {
+		rec := doJSON(t, h, step.method, step.path, step.token, step.body)
+		if rec.Code != step.status {
+			t.Fatalf("%s: status = %d, want %d (body: %s)", step.name, rec.Code, step.status, rec.Body.String())
+		}
+	}
+	if got := userByEmail(t, "ana@x.io").Role; got != models.RoleModerator {
+		t.Fatalf("role after the changes = %q, want %q", got, models.RoleModerator)
+	}
+
+	// El listado incluye los roles pero nunca las contraseñas
+	rec := doJSON(t, h, http.MethodGet, "/api/v1/admin/users?limit=10", admin, "")
+	var users []models.User
+	if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil {
+		t.Fatalf("users response: %v (body: %s)", err, rec.Body.String())
+	}
+	roles := map[string]string{}
+	for _, listed := range users {
+		if listed.Password != "" {
+			t.Errorf("user %s listed with its password hash", listed.Email)
+		}
+		roles[listed.Email] = listed.Role
+	}
+	want := map[string]string{"ana@x.io": models.RoleModerator, "root@x.io": models.RoleAdmin}
+	if !maps.Equal(roles, want) {
+		t.Fatalf("listed roles = %v, want %v", roles, want)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"afperdomo2/go/rest-ws/utils"
+	"errors"
+	"log/slog"
+)
+
+// newKeySet construye el conjunto de claves de firma de los access tokens según la configuración
+//
+// Claves registradas:
+//   - JWTSecret (si existe) como clave HS256 sin kid, que también verifica los tokens emitidos
+//     antes de pasar a un algoritmo asimétrico
+//   - Todas las claves *.pem de JWTKeysDir (el kid es el nombre del archivo)
+//
+// Clave activa (la que firma):
+//   - HS256: el secreto compartido
+//   - RS256/EdDSA: JWTActiveKid o, si no se indica, la última clave privada del algoritmo
+//     (por nombre de archivo). Sin claves disponibles se genera una clave efímera, válida
+//     solo mientras el proceso esté vivo (útil en desarrollo)
+func newKeySet(config *ServerConfig) (*utils.KeySet, error) {
+	keys := utils.NewKeySet()
+
+	if config.JWTSecret != "" {
+		keys.Add(utils.NewHMACKey("", config.JWTSecret))
+	}
+
+	if config.JWTAlgorithm == utils.AlgHS256 {
+		if config.JWTSecret == "" {
+			return nil, errors.New("JWT secret must be specified")
+		}
+		return keys, keys.SetActive("")
+	}
+
+	active := config.JWTActiveKid
+	if config.JWTKeysDir != "" {
+		loaded, err := utils.LoadSigningKeysDir(config.JWTKeysDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range loaded {
+			keys.Add(key)
+			if config.JWTActiveKid == "" && key.CanSign() && key.Method.Alg() == config.JWTAlgorithm {
+				active = key.Kid
+			}
+		}
+	}
+
+	if active == "" {
+		kid, err := utils.GenerateRandomToken(8)
+		if err != nil {
+			return nil, err
+		}
+		key, err := utils.GenerateSigningKey("ephemeral-"+kid, config.JWTAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		slog.Warn("no signing keys configured, using an ephemeral key", "algorithm", config.JWTAlgorithm, "kid", key.Kid)
+		keys.Add(key)
+		active = key.Kid
+	}
+
+	if err := keys.SetActive(active); err != nil {
+		return nil, err
+	}
+	if key, _ := keys.Active(); key.Method.Alg() != config.JWTAlgorithm {
+		return nil, errors.New("active signing key " + active + " does not use " + config.JWTAlgorithm)
+	}
+	return keys, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"afperdomo2/go/rest-ws/utils"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeRSAKeys escribe una clave privada RSA en PEM por cada kid y devuelve el directorio
+func writeRSAKeys(t *testing.T, kids ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, kid := range kids {
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})
+		if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestNewKeySetRotation(t *testing.T) {
+	dir := writeRSAKeys(t, "2025-01", "2025-02")
+
+	// Antes de la rotación, JWT_ACTIVE_KID fija la clave anterior
+	before, err := newKeySet(&ServerConfig{JWTAlgorithm: utils.AlgRS256, JWTKeysDir: dir, JWTActiveKid: "2025-01"})
+	if err != nil {
+		t.Fatalf("newKeySet: %v", err)
+	}
+	oldToken, err := before.Sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// Sin JWT_ACTIVE_KID firma la última clave por nombre; los tokens de la anterior siguen valiendo
+	after, err := newKeySet(&ServerConfig{JWTAlgorithm: utils.AlgRS256, JWTKeysDir: dir, JWTSecret: "legacy-secret"})
+	if err != nil {
+		t.Fatalf("newKeySet: %v", err)
+	}
+	if active, _ := after.Active(); active.Kid != "2025-02" {
+		t.Fatalf("active kid = %q, want 2025-02", active.Kid)
+	}
+	if _, err := jwt.Parse(oldToken, after.Keyfunc); err != nil {
+		t.Fatalf("token signed before the rotation: %v", err)
+	}
+
+	// El secreto HMAC verifica los tokens sin kid, pero nunca se publica
+	if jwks := after.JWKS(); len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want the 2 RSA keys", len(jwks.Keys))
+	}
+}
+
+func TestNewKeySetErrors(t *testing.T) {
+	dir := writeRSAKeys(t, "2025-01")
+	cases := []struct {
+		name   string
+		config ServerConfig
+		err    error
+	}{
+		{"hs256 without secret", ServerConfig{JWTAlgorithm: utils.AlgHS256}, nil},
+		{"unknown active kid", ServerConfig{JWTAlgorithm: utils.AlgRS256, JWTKeysDir: dir, JWTActiveKid: "2024-01"}, utils.ErrUnknownKey},
+		{"active kid with another algorithm", ServerConfig{JWTAlgorithm: utils.AlgEdDSA, JWTKeysDir: dir, JWTActiveKid: "2025-01"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newKeySet(&tc.config)
+			if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+				t.Fatalf("newKeySet = %v, want an error (%v)", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"afperdomo2/go/rest-ws/mailer"
+	"errors"
+	"os"
+)
+
+const (
+	MailDriverStdout = "stdout" // Escribe los emails en la salida estándar (por defecto, desarrollo)
+	MailDriverFile   = "file"   // Añade los emails al archivo MailFile (desarrollo)
+	MailDriverSMTP   = "smtp"   // Entrega los emails al servidor SMTP configurado (producción)
+
+	DefaultMailFrom = "no-reply@localhost"
+)
+
+// newMailer construye el Mailer según MailDriver
+func newMailer(config *ServerConfig) (mailer.Mailer, error) {
+	if config.MailFrom == "" {
+		config.MailFrom = DefaultMailFrom
+	}
+
+	switch config.MailDriver {
+	case "", MailDriverStdout:
+		return mailer.NewWriterMailer(os.Stdout, config.MailFrom), nil
+	case MailDriverFile:
+		if config.MailFile == "" {
+			return nil, errors.New("mail file must be specified")
+		}
+		return mailer.NewFileMailer(config.MailFile, config.MailFrom)
+	case MailDriverSMTP:
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.MailFrom,
+		})
+	default:
+		return nil, errors.New("unsupported mail driver: " + config.MailDriver)
+	}
+}
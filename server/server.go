@@ -1,112 +1,443 @@
-// Package server proporciona la infraestructura básica para un servidor HTTP REST
-// Incluye configuración, inicialización y gestión del ciclo de vida del servidor
-package server
-
-import (
-	"afperdomo2/go/rest-ws/database"
-	"afperdomo2/go/rest-ws/repository"
-	"afperdomo2/go/rest-ws/websockets"
-	"context"
-	"errors"
-	"log"
-	"net/http"
-
-	"github.com/gorilla/mux"
-	"github.com/rs/cors"
-)
-
-// Server define la interfaz que debe implementar cualquier servidor
-// Proporciona acceso a la configuración del servidor
-type Server interface {
-	Config() *ServerConfig
-	Hub() *websockets.Hub // Método para obtener el Hub de WebSockets
-}
-
-// ServerConfig contiene todos los parámetros de configuración necesarios para el servidor
-type ServerConfig struct {
-	Port        string // Puerto en el que el servidor escuchará (ej: ":8080")
-	JWTSecret   string // Clave secreta para firmar y verificar tokens JWT
-	DatabaseURL string // URL de conexión a la base de datos
-}
-
-// Broker es la implementación concreta del servidor HTTP
-// Encapsula la configuración y el router de rutas
-type Broker struct {
-	config *ServerConfig   // Configuración del servidor
-	router *mux.Router     // Router HTTP para manejar las rutas
-	hub    *websockets.Hub // Hub de WebSockets
-}
-
-// Config devuelve la configuración actual del broker
-// Implementa la interfaz Server
-func (b *Broker) Config() *ServerConfig {
-	return b.config // Retorna la configuración del servidor
-}
-
-// Hub devuelve una nueva instancia del Hub de WebSockets
-// Implementa la interfaz Server
-func (b *Broker) Hub() *websockets.Hub {
-	return b.hub // Retorna el Hub de WebSockets asociado al broker
-}
-
-// NewServer crea una nueva instancia del servidor HTTP
-// Valida que todos los parámetros de configuración requeridos estén presentes
-// Retorna un error si algún parámetro obligatorio está vacío
-//
-// Parámetros:
-//   - ctx: Contexto para la creación del servidor
-//   - config: Configuración del servidor que incluye puerto, JWT secret y URL de base de datos
-//
-// Retorna:
-//   - *Broker: Instancia del servidor configurada
-//   - error: Error si la configuración es inválida
-func NewServer(ctx context.Context, config *ServerConfig) (*Broker, error) {
-	if config.Port == "" {
-		return nil, errors.New("port must be specified")
-	}
-	if config.JWTSecret == "" {
-		return nil, errors.New("JWT secret must be specified")
-	}
-	if config.DatabaseURL == "" {
-		return nil, errors.New("database URL must be specified")
-	}
-	// Crea una nueva instancia del broker con la configuración y un router vacío
-	// El router se inicializa aquí para que esté listo para usar al iniciar el servidor
-	broker := &Broker{
-		config: config,              // Asigna la configuración del servidor
-		router: mux.NewRouter(),     // Inicializa el router de Gorilla Mux
-		hub:    websockets.NewHub(), // Inicializa el Hub de WebSockets
-	}
-	return broker, nil
-}
-
-// Start inicia el servidor HTTP y lo pone en modo de escucha
-// Configura las rutas usando la función binder proporcionada y luego
-// inicia el servidor en el puerto especificado en la configuración
-//
-// Parámetros:
-//   - binder: Función que recibe el servidor y router para configurar las rutas
-//
-// Nota: Esta función bloquea la ejecución hasta que el servidor se detenga
-// En caso de error al iniciar el servidor, el programa terminará con log.Fatal
-func (b *Broker) Start(binder func(s Server, r *mux.Router)) {
-	b.router = mux.NewRouter()
-	binder(b, b.router)
-
-	// corsHandler := cors.Default().Handler(b.router) // Configura CORS para el router
-	corsHandler := cors.AllowAll().Handler(b.router)
-
-	repo, err := database.NewPostgresRepository(b.config.DatabaseURL)
-	if err != nil {
-		log.Fatal("❌ Error connecting to database:", err)
-	}
-	repository.SetRepository(repo)
-
-	// Configura el Hub de WebSockets en el broker
-	go b.hub.Run() // Inicia el Hub en una goroutine para manejar conexiones WebSocket
-
-	log.Println("🚀 Server started on port", b.config.Port)
-	if err := http.ListenAndServe(b.config.Port, corsHandler); err != nil {
-		log.Fatal("❌ Error starting server:", err)
-	}
-}
+// Package server proporciona la infraestructura básica para un servidor HTTP REST
+// Incluye configuración, inicialización y gestión del ciclo de vida del servidor
+package server
+
+import (
+	"afperdomo2/go/rest-ws/database"
+	"afperdomo2/go/rest-ws/mailer"
+	"afperdomo2/go/rest-ws/metrics"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/utils"
+	"afperdomo2/go/rest-ws/websockets"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/cors"
+)
+
+// Server define la interfaz que debe implementar cualquier servidor
+// Proporciona acceso a la configuración del servidor
+type Server interface {
+	Config() *ServerConfig
+	Hub() *websockets.Hub             // Método para obtener el Hub de WebSockets
+	Keys() *utils.KeySet              // Claves de firma y verificación de los access tokens
+	TokenOptions() utils.TokenOptions // Claves y reglas de validación de los access tokens
+	ShuttingDown() bool               // Indica que el servidor inició el apagado ordenado
+	Mailer() mailer.Mailer            // Envío de emails (verificación de email y restablecimiento de contraseña)
+
+	// Background ejecuta una tarea que la respuesta no espera (p. ej. enviar un email);
+	// el apagado espera a que termine antes de cerrar el repositorio
+	Background(ctx context.Context, task func(ctx context.Context))
+}
+
+// ServerConfig contiene todos los parámetros de configuración necesarios para el servidor
+type ServerConfig struct {
+	Port           string // Puerto en el que el servidor escuchará (ej: ":8080")
+	JWTSecret      string // Clave secreta para firmar y verificar tokens JWT con HS256
+	DatabaseURL    string // URL de conexión a la base de datos
+	DatabaseDriver string // Implementación del repositorio: "postgres" (por defecto) o "memory"
+	AutoMigrate    bool   // Aplica las migraciones pendientes al iniciar (solo con "postgres")
+
+	JWTAlgorithm string // Algoritmo de firma de los access tokens: "HS256" (por defecto), "RS256" o "EdDSA"
+	JWTKeysDir   string // Directorio con las claves PEM (el kid es el nombre del archivo)
+	JWTActiveKid string // kid de la clave que firma los nuevos tokens (por defecto, la última del directorio)
+
+	JWTIssuer   string        // Claim iss de los tokens emitidos y exigido al validarlos (por defecto "rest-ws")
+	JWTAudience string        // Claim aud de los tokens emitidos y exigido al validarlos (por defecto "rest-ws")
+	JWTLeeway   time.Duration // Tolerancia de desfase de reloj al validar exp, nbf e iat (por defecto 30 segundos)
+
+	AccessTokenTTL  time.Duration // Duración de los access tokens JWT (por defecto 15 minutos)
+	RefreshTokenTTL time.Duration // Duración de los refresh tokens (por defecto 7 días)
+
+	AuthCookieSecure   bool     // Marca la cookie del access token como Secure (solo HTTPS)
+	CORSAllowedOrigins []string // Orígenes que pueden llamar a la API con credenciales (cookies); vacío: cualquier origen sin credenciales
+	TrustedProxies     []string // IPs o redes CIDR de los proxies cuyo X-Forwarded-For se acepta para obtener la IP del cliente
+
+	ShutdownTimeout    time.Duration // Tiempo máximo para terminar las requests en curso al apagar (por defecto 15 segundos)
+	ShutdownDrainDelay time.Duration // Tiempo que /readyz responde 503 antes de dejar de aceptar conexiones (por defecto 0)
+
+	WSSendBufferSize     int    // Mensajes pendientes por cliente WebSocket (por defecto 256)
+	WSSlowConsumerPolicy string // Política para clientes lentos: "drop_oldest" (por defecto), "drop_newest" o "disconnect"
+
+	MailDriver   string // Entrega de los emails: "stdout" (por defecto), "file" o "smtp"
+	MailFrom     string // Remitente de los emails (por defecto "no-reply@localhost")
+	MailFile     string // Archivo al que se añaden los emails con MailDriver "file"
+	SMTPHost     string // Servidor SMTP con MailDriver "smtp"
+	SMTPPort     string // Puerto del servidor SMTP (por defecto "587")
+	SMTPUsername string // Usuario del servidor SMTP; vacío: sin autenticación
+	SMTPPassword string // Contraseña del servidor SMTP
+
+	PasswordResetURL string        // Página del frontend que recibe el token (?token=...); vacío: el email solo incluye el token
+	PasswordResetTTL time.Duration // Validez de los tokens para restablecer la contraseña (por defecto 1 hora)
+
+	EmailVerificationURL string        // Página del frontend que recibe el token (?token=...); vacío: el email solo incluye el token
+	EmailVerificationTTL time.Duration // Validez de los tokens de verificación de email (por defecto 24 horas)
+	RequireVerifiedEmail bool          // Solo los usuarios con el email verificado pueden crear posts
+}
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+	DefaultJWTIssuer   = "rest-ws"
+	DefaultJWTAudience = "rest-ws"
+	DefaultJWTLeeway   = 30 * time.Second
+
+	DefaultShutdownTimeout = 15 * time.Second
+
+	DefaultPasswordResetTTL     = time.Hour
+	DefaultEmailVerificationTTL = 24 * time.Hour
+)
+
+const (
+	DriverPostgres = "postgres" // Repositorio respaldado por PostgreSQL
+	DriverMemory   = "memory"   // Repositorio en memoria, útil para pruebas y ejecuciones locales
+)
+
+// Broker es la implementación concreta del servidor HTTP
+// Encapsula la configuración y el router de rutas
+type Broker struct {
+	config *ServerConfig   // Configuración del servidor
+	router *mux.Router     // Router HTTP para manejar las rutas
+	hub    *websockets.Hub // Hub de WebSockets
+	keys   *utils.KeySet   // Claves de firma de los access tokens
+	mailer mailer.Mailer   // Envío de emails
+	hubRun sync.Once       // El Hub se inicia una sola vez aunque Setup se llame varias veces
+
+	shuttingDown atomic.Bool // Se activa al iniciar el apagado; /readyz deja de estar listo
+
+	background       sync.WaitGroup     // Tareas lanzadas con Background que aún no han terminado
+	backgroundCtx    context.Context    // Se cancela si el apagado no puede esperar más a las tareas
+	cancelBackground context.CancelFunc // Cancela backgroundCtx
+}
+
+// Config devuelve la configuración actual del broker
+// Implementa la interfaz Server
+func (b *Broker) Config() *ServerConfig {
+	return b.config // Retorna la configuración del servidor
+}
+
+// Hub devuelve una nueva instancia del Hub de WebSockets
+// Implementa la interfaz Server
+func (b *Broker) Hub() *websockets.Hub {
+	return b.hub // Retorna el Hub de WebSockets asociado al broker
+}
+
+// Keys devuelve el conjunto de claves de firma de los access tokens
+// Implementa la interfaz Server
+func (b *Broker) Keys() *utils.KeySet {
+	return b.keys
+}
+
+// Mailer devuelve el Mailer configurado con MailDriver
+// Implementa la interfaz Server
+func (b *Broker) Mailer() mailer.Mailer {
+	return b.mailer
+}
+
+// TokenOptions devuelve las claves y las reglas con las que se validan los access tokens
+// Implementa la interfaz Server
+func (b *Broker) TokenOptions() utils.TokenOptions {
+	return utils.TokenOptions{
+		Keys:     b.keys,
+		Issuer:   b.config.JWTIssuer,
+		Audience: b.config.JWTAudience,
+		Leeway:   b.config.JWTLeeway,
+	}
+}
+
+// ShuttingDown indica si el servidor inició el apagado ordenado
+// Implementa la interfaz Server
+func (b *Broker) ShuttingDown() bool {
+	return b.shuttingDown.Load()
+}
+
+// Background ejecuta task en una goroutine sin que la request que la lanza la espere
+// El contexto de task conserva los valores de ctx (el request_id para los logs) pero no se cancela
+// al terminar la request; solo se cancela si el apagado agota ShutdownTimeout esperando a las tareas
+// Implementa la interfaz Server
+func (b *Broker) Background(ctx context.Context, task func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(b.backgroundCtx, cancel)
+
+	b.background.Add(1)
+	go func() {
+		defer b.background.Done()
+		defer cancel()
+		defer stop()
+		task(ctx)
+	}()
+}
+
+// waitBackground espera a las tareas lanzadas con Background hasta que ctx expire;
+// entonces cancela las que queden y retorna el error de ctx
+func (b *Broker) waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		b.cancelBackground()
+		return ctx.Err()
+	}
+}
+
+// NewServer crea una nueva instancia del servidor HTTP
+// Valida que todos los parámetros de configuración requeridos estén presentes
+// Retorna un error si algún parámetro obligatorio está vacío
+//
+// Parámetros:
+//   - ctx: Contexto para la creación del servidor
+//   - config: Configuración del servidor que incluye puerto, JWT secret y URL de base de datos
+//
+// Retorna:
+//   - *Broker: Instancia del servidor configurada
+//   - error: Error si la configuración es inválida
+func NewServer(ctx context.Context, config *ServerConfig) (*Broker, error) {
+	if config.Port == "" {
+		return nil, errors.New("port must be specified")
+	}
+	if config.JWTAlgorithm == "" {
+		config.JWTAlgorithm = utils.AlgHS256
+	}
+	if config.JWTAlgorithm != utils.AlgHS256 && config.JWTAlgorithm != utils.AlgRS256 && config.JWTAlgorithm != utils.AlgEdDSA {
+		return nil, errors.New("unsupported JWT algorithm: " + config.JWTAlgorithm)
+	}
+	if config.DatabaseDriver == "" {
+		config.DatabaseDriver = DriverPostgres
+	}
+	if config.DatabaseDriver != DriverPostgres && config.DatabaseDriver != DriverMemory {
+		return nil, errors.New("unsupported database driver: " + config.DatabaseDriver)
+	}
+	// La URL solo es obligatoria cuando se usa PostgreSQL
+	if config.DatabaseDriver == DriverPostgres && config.DatabaseURL == "" {
+		return nil, errors.New("database URL must be specified")
+	}
+	if config.AccessTokenTTL <= 0 {
+		config.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+	if config.RefreshTokenTTL <= 0 {
+		config.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	if config.JWTIssuer == "" {
+		config.JWTIssuer = DefaultJWTIssuer
+	}
+	if config.JWTAudience == "" {
+		config.JWTAudience = DefaultJWTAudience
+	}
+	if config.JWTLeeway <= 0 {
+		config.JWTLeeway = DefaultJWTLeeway
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if config.PasswordResetTTL <= 0 {
+		config.PasswordResetTTL = DefaultPasswordResetTTL
+	}
+	if config.EmailVerificationTTL <= 0 {
+		config.EmailVerificationTTL = DefaultEmailVerificationTTL
+	}
+	keys, err := newKeySet(config)
+	if err != nil {
+		return nil, err
+	}
+	mail, err := newMailer(config)
+	if err != nil {
+		return nil, err
+	}
+	hub, err := websockets.NewHub(websockets.HubConfig{
+		SendBufferSize:     config.WSSendBufferSize,
+		SlowConsumerPolicy: websockets.SlowConsumerPolicy(config.WSSlowConsumerPolicy),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Crea una nueva instancia del broker con la configuración y un router vacío
+	// El router se inicializa aquí para que esté listo para usar al iniciar el servidor
+	broker := &Broker{
+		config: config,          // Asigna la configuración del servidor
+		router: mux.NewRouter(), // Inicializa el router de Gorilla Mux
+		hub:    hub,             // Hub de WebSockets
+		keys:   keys,            // Claves de firma de los tokens
+		mailer: mail,            // Envío de emails
+	}
+	broker.backgroundCtx, broker.cancelBackground = context.WithCancel(context.Background())
+	return broker, nil
+}
+
+// Setup prepara el servidor sin ponerlo en modo de escucha
+// Configura las rutas, conecta el repositorio según DatabaseDriver e inicia el Hub
+// Retorna el http.Handler final (con CORS), útil para ejecutar el servidor con httptest
+//
+// Parámetros:
+//   - binder: Función que recibe el servidor y router para configurar las rutas
+func (b *Broker) Setup(binder func(s Server, r *mux.Router)) (http.Handler, error) {
+	// La IP del cliente (rate limiting, protección del login) depende de los proxies de confianza
+	if err := utils.SetTrustedProxies(b.config.TrustedProxies); err != nil {
+		return nil, errors.New("invalid trusted proxy: " + err.Error())
+	}
+
+	b.router = mux.NewRouter()
+	binder(b, b.router)
+
+	// corsHandler := cors.Default().Handler(b.router) // Configura CORS para el router
+	corsHandler := b.newCORS().Handler(b.router)
+
+	repo, err := b.newRepository(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	repository.SetRepository(repo)
+
+	if err := metrics.RegisterHub(b.hub); err != nil {
+		return nil, err
+	}
+
+	// El autenticador del Hub lo configura el binder (ver Hub.SetAuthenticator): la validación
+	// completa de los tokens vive en services, que depende de este paquete
+	// Inicia el Hub en una goroutine para manejar conexiones WebSocket (solo en el primer Setup)
+	b.hubRun.Do(func() { go b.hub.Run() })
+
+	return corsHandler, nil
+}
+
+// newCORS configura CORS para el router
+// Sin orígenes configurados se permite cualquier origen, pero sin credenciales; la cookie
+// del access token solo viaja desde los orígenes listados en CORSAllowedOrigins
+func (b *Broker) newCORS() *cors.Cors {
+	if len(b.config.CORSAllowedOrigins) == 0 {
+		return cors.AllowAll()
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins: b.config.CORSAllowedOrigins,
+		AllowedMethods: []string{
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+			http.MethodOptions,
+		},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: true,
+	})
+}
+
+// newRepository crea la implementación del repositorio indicada en la configuración
+// Con AutoMigrate, aplica las migraciones pendientes antes de empezar a atender requests
+func (b *Broker) newRepository(ctx context.Context) (repository.Repository, error) {
+	if b.config.DatabaseDriver == DriverMemory {
+		slog.Info("using in-memory repository")
+		return database.NewMemoryRepository(), nil
+	}
+	repo, err := database.NewPostgresRepository(b.config.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if b.config.AutoMigrate {
+		if err := migrate(ctx, repo); err != nil {
+			repo.Close()
+			return nil, err
+		}
+	}
+	if err := metrics.RegisterDBStats(DriverPostgres, repo.DB()); err != nil {
+		repo.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// migrate aplica las migraciones pendientes del esquema
+func migrate(ctx context.Context, repo *database.PostgresRepository) error {
+	migrator, err := repo.Migrator()
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range applied {
+		slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+// Start inicia el servidor HTTP y lo pone en modo de escucha
+// Configura las rutas usando la función binder proporcionada y luego
+// inicia el servidor en el puerto especificado en la configuración
+//
+// Parámetros:
+//   - ctx: Al cancelarse (p. ej. por SIGINT/SIGTERM) el servidor se apaga ordenadamente
+//   - binder: Función que recibe el servidor y router para configurar las rutas
+//
+// Nota: Esta función bloquea la ejecución hasta que el servidor se detenga
+// Retorna un error si el servidor no pudo iniciar o si el apagado no terminó limpiamente
+func (b *Broker) Start(ctx context.Context, binder func(s Server, r *mux.Router)) error {
+	handler, err := b.Setup(binder)
+	if err != nil {
+		return fmt.Errorf("setting up server: %w", err)
+	}
+
+	httpServer := &http.Server{
+		Addr:    b.config.Port,
+		Handler: handler,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server started", "addr", b.config.Port)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// El servidor no pudo iniciar (p. ej. el puerto está ocupado): se liberan los recursos
+		return errors.Join(fmt.Errorf("starting server: %w", err), b.shutdown(httpServer))
+	case <-ctx.Done():
+		return b.shutdown(httpServer)
+	}
+}
+
+// shutdown apaga el servidor ordenadamente, con un plazo máximo de ShutdownTimeout:
+// 1. Marca el servidor como no listo (/readyz responde 503) y espera ShutdownDrainDelay,
+// para que el balanceador deje de enviarle tráfico
+// 2. Deja de aceptar conexiones y espera a que terminen las requests en curso
+// 3. Envía un close frame a todos los clientes WebSocket y detiene el Hub
+// 4. Espera a las tareas en segundo plano (ver Background), que pueden usar el repositorio
+// 5. Cierra el pool de conexiones de la base de datos
+func (b *Broker) shutdown(httpServer *http.Server) error {
+	slog.Info("shutting down server", "drain_delay", b.config.ShutdownDrainDelay.String(), "timeout", b.config.ShutdownTimeout.String())
+	b.shuttingDown.Store(true)
+	time.Sleep(b.config.ShutdownDrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := httpServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+	}
+	if err := b.hub.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("websocket hub shutdown: %w", err))
+	}
+	if err := b.waitBackground(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("waiting for background tasks: %w", err))
+	}
+	if err := repository.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing repository: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBroker(t *testing.T) *Broker {
+	t.Helper()
+	b, err := NewServer(context.Background(), &ServerConfig{Port: ":0", JWTSecret: "test-secret", DatabaseDriver: DriverMemory})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return b
+}
+
+func TestBackgroundOutlivesRequest(t *testing.T) {
+	b := newTestBroker(t)
+
+	// La tarea sigue aunque el contexto de la request se cancele, y el apagado la espera
+	request, cancelRequest := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	var taskErr error
+	b.Background(request, func(ctx context.Context) {
+		<-release
+		taskErr = ctx.Err()
+	})
+	cancelRequest()
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.waitBackground(ctx); err != nil {
+		t.Fatalf("waitBackground = %v, want nil", err)
+	}
+	if taskErr != nil {
+		t.Fatalf("task context error = %v, want nil", taskErr)
+	}
+}
+
+func TestBackgroundCanceledAfterShutdownTimeout(t *testing.T) {
+	b := newTestBroker(t)
+
+	canceled := make(chan error, 1)
+	b.Background(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.waitBackground(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waitBackground = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Agotado el plazo, las tareas pendientes se cancelan
+	select {
+	case err := <-canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("task context error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending task was not canceled")
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LOGIN_FREE_ATTEMPTS_EMAIL = 5                // Fallos seguidos por email antes de aplicar el backoff
+	LOGIN_FREE_ATTEMPTS_IP    = 20               // Fallos por IP antes de aplicar el backoff (una IP puede agrupar a muchos usuarios)
+	LOGIN_BASE_DELAY          = time.Second      // Espera tras el primer fallo por encima del límite; se duplica con cada fallo
+	LOGIN_MAX_LOCKOUT         = 15 * time.Minute // Espera máxima (bloqueo temporal)
+	LOGIN_ATTEMPTS_TTL        = time.Hour        // Los fallos se olvidan tras este tiempo sin nuevos fallos
+)
+
+// loginAttempts son los fallos acumulados por un email o por una IP
+type loginAttempts struct {
+	failures    int
+	pending     int // Intentos admitidos por Check que aún no se han resuelto
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginGuard protege el login frente a ataques de fuerza bruta
+// Cuenta los fallos por email y por IP: superado el número de intentos libres, cada nuevo fallo
+// obliga a esperar el doble que el anterior (backoff exponencial) hasta LOGIN_MAX_LOCKOUT
+// Los emails que no existen se cuentan igual que los existentes, para no revelar qué cuentas existen
+//
+// Check reserva el intento antes de comprobar la contraseña, así un cliente no puede lanzar
+// muchos intentos en paralelo mientras el bloqueo del primero aún no se ha registrado.
+// Cada Check que devuelve 0 debe resolverse con Fail, Succeed o Release
+//
+// Los contadores viven en memoria: cada instancia de la API lleva los suyos y se pierden al reiniciar
+type LoginGuard struct {
+	mutex     sync.Mutex
+	attempts  map[string]*loginAttempts // Claves "email:<email>" e "ip:<ip>"
+	lastSweep time.Time
+}
+
+// NewLoginGuard crea un LoginGuard sin fallos registrados
+func NewLoginGuard() *LoginGuard {
+	return &LoginGuard{
+		attempts: make(map[string]*loginAttempts),
+	}
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check indica cuánto debe esperar el cliente antes de volver a intentar el login
+// con ese email o desde esa IP. Si puede intentarlo ya, devuelve 0 y reserva el intento
+//
+// Los intentos libres se pueden hacer en paralelo, pero una vez agotados solo se admite
+// un intento en curso por clave: el siguiente espera lo que impondría el fallo del que está en curso
+func (lg *LoginGuard) Check(email string, ip string) time.Duration {
+	lg.mutex.Lock()
+	defer lg.mutex.Unlock()
+
+	now := time.Now()
+	lg.sweep(now)
+
+	keys := [...]struct {
+		key          string
+		freeAttempts int
+	}{
+		{emailKey(email), LOGIN_FREE_ATTEMPTS_EMAIL},
+		{ipKey(ip), LOGIN_FREE_ATTEMPTS_IP},
+	}
+	var wait time.Duration
+	for _, k := range keys {
+		attempts := lg.entry(k.key, now)
+		if attempts.lockedUntil.After(now) {
+			wait = max(wait, attempts.lockedUntil.Sub(now))
+		}
+		if attempts.pending > 0 {
+			wait = max(wait, lockoutDelay(attempts.failures+attempts.pending-k.freeAttempts))
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, k := range keys {
+		lg.entry(k.key, now).pending++
+	}
+	return 0
+}
+
+// Fail resuelve un intento reservado como fallido y devuelve la espera impuesta a partir de ahora (0 si aún no hay)
+func (lg *LoginGuard) Fail(email string, ip string) time.Duration {
+	lg.mutex.Lock()
+	defer lg.mutex.Unlock()
+
+	now := time.Now()
+	emailWait := lg.fail(emailKey(email), LOGIN_FREE_ATTEMPTS_EMAIL, now)
+	ipWait := lg.fail(ipKey(ip), LOGIN_FREE_ATTEMPTS_IP, now)
+	return max(emailWait, ipWait)
+}
+
+// fail suma un fallo a la clave, libera su intento reservado y calcula su bloqueo.
+// Debe llamarse con el mutex tomado
+func (lg *LoginGuard) fail(key string, freeAttempts int, now time.Time) time.Duration {
+	attempts := lg.entry(key, now)
+	attempts.pending = max(attempts.pending-1, 0)
+	attempts.failures++
+	attempts.lastFailure = now
+
+	delay := lockoutDelay(attempts.failures - freeAttempts)
+	if delay > 0 {
+		attempts.lockedUntil = now.Add(delay)
+	}
+	return delay
+}
+
+// lockoutDelay es la espera tras un fallo que supera en excess los intentos libres (0 si no los supera)
+func lockoutDelay(excess int) time.Duration {
+	if excess <= 0 {
+		return 0
+	}
+	if excess >= 20 { // 2^20 segundos ya superan LOGIN_MAX_LOCKOUT; así el desplazamiento nunca desborda
+		return LOGIN_MAX_LOCKOUT
+	}
+	return min(LOGIN_BASE_DELAY<<(excess-1), LOGIN_MAX_LOCKOUT)
+}
+
+// entry devuelve el contador de la clave, creándolo si no existe
+// Si los fallos ya se olvidaron (LOGIN_ATTEMPTS_TTL) se reinician, conservando los intentos en curso.
+// Debe llamarse con el mutex tomado
+func (lg *LoginGuard) entry(key string, now time.Time) *loginAttempts {
+	attempts, ok := lg.attempts[key]
+	if !ok {
+		attempts = &loginAttempts{}
+		lg.attempts[key] = attempts
+	} else if attempts.failures > 0 && now.Sub(attempts.lastFailure) > LOGIN_ATTEMPTS_TTL {
+		*attempts = loginAttempts{pending: attempts.pending}
+	}
+	return attempts
+}
+
+// Succeed resuelve un intento reservado como correcto y borra los fallos del email
+// Los fallos de la IP se mantienen: si no, un atacante con una cuenta propia podría
+// reiniciar su contador entre intentos
+func (lg *LoginGuard) Succeed(email string, ip string) {
+	lg.mutex.Lock()
+	defer lg.mutex.Unlock()
+	lg.reset(emailKey(email))
+	lg.release(emailKey(email))
+	lg.release(ipKey(ip))
+}
+
+// Release libera un intento reservado sin contarlo como fallo ni como acierto
+// (p. ej. si el login no pudo completarse por un error interno)
+func (lg *LoginGuard) Release(email string, ip string) {
+	lg.mutex.Lock()
+	defer lg.mutex.Unlock()
+	lg.release(emailKey(email))
+	lg.release(ipKey(ip))
+}
+
+// release descuenta un intento en curso y borra el contador si ya no guarda nada
+// Debe llamarse con el mutex tomado
+func (lg *LoginGuard) release(key string) {
+	attempts, ok := lg.attempts[key]
+	if !ok {
+		return
+	}
+	attempts.pending = max(attempts.pending-1, 0)
+	if attempts.pending == 0 && attempts.failures == 0 {
+		delete(lg.attempts, key)
+	}
+}
+
+// reset borra los fallos y el bloqueo de la clave, conservando los intentos en curso
+// Retorna false si la clave no tenía fallos registrados. Debe llamarse con el mutex tomado
+func (lg *LoginGuard) reset(key string) bool {
+	attempts, ok := lg.attempts[key]
+	if !ok {
+		return false
+	}
+	hadFailures := attempts.failures > 0
+	*attempts = loginAttempts{pending: attempts.pending}
+	if attempts.pending == 0 {
+		delete(lg.attempts, key)
+	}
+	return hadFailures
+}
+
+// Unlock desbloquea un email (p. ej. desde el endpoint de administración)
+// Retorna false si el email no tenía fallos registrados
+func (lg *LoginGuard) Unlock(email string) bool {
+	lg.mutex.Lock()
+	defer lg.mutex.Unlock()
+	return lg.reset(emailKey(email))
+}
+
+// sweep elimina, como mucho una vez por minuto, los contadores olvidados
+// para que el mapa no crezca sin límite. Debe llamarse con el mutex tomado
+func (lg *LoginGuard) sweep(now time.Time) {
+	if now.Sub(lg.lastSweep) < time.Minute {
+		return
+	}
+	lg.lastSweep = now
+	for key, attempts := range lg.attempts {
+		if attempts.pending == 0 && now.Sub(attempts.lastFailure) > LOGIN_ATTEMPTS_TTL && !attempts.lockedUntil.After(now) {
+			delete(lg.attempts, key)
+		}
+	}
+}
+
+var LoginGuardInstance = NewLoginGuard()
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoginGuardConcurrentChecks(t *testing.T) {
+	lg := NewLoginGuard()
+
+	// Sin resolver ningún intento, solo se admiten los libres más uno:
+	// el mismo número que en secuencia antes del primer bloqueo
+	var admitted atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lg.Check("ana@x.io", "192.0.2.1") == 0 {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := admitted.Load(); got != LOGIN_FREE_ATTEMPTS_EMAIL+1 {
+		t.Fatalf("admitted %d concurrent attempts, want %d", got, LOGIN_FREE_ATTEMPTS_EMAIL+1)
+	}
+
+	for range admitted.Load() {
+		lg.Fail("ana@x.io", "192.0.2.1")
+	}
+	if wait := lg.Check("ana@x.io", "192.0.2.1"); wait <= 0 || wait > LOGIN_BASE_DELAY {
+		t.Fatalf("wait after %d failures = %v, want up to %v", admitted.Load(), wait, LOGIN_BASE_DELAY)
+	}
+}
+
+func TestLoginGuardSettle(t *testing.T) {
+	lg := NewLoginGuard()
+	for range LOGIN_FREE_ATTEMPTS_EMAIL {
+		if wait := lg.Check("ana@x.io", "192.0.2.1"); wait != 0 {
+			t.Fatalf("Check = %v, want 0", wait)
+		}
+		lg.Fail("ana@x.io", "192.0.2.1")
+	}
+
+	// Agotados los intentos libres, el intento en curso bloquea al siguiente hasta resolverse
+	if wait := lg.Check("ana@x.io", "192.0.2.1"); wait != 0 {
+		t.Fatalf("Check = %v, want 0", wait)
+	}
+	if wait := lg.Check("ana@x.io", "192.0.2.1"); wait != LOGIN_BASE_DELAY {
+		t.Fatalf("Check with an attempt in flight = %v, want %v", wait, LOGIN_BASE_DELAY)
+	}
+	lg.Release("ana@x.io", "192.0.2.1")
+	if wait := lg.Check("ana@x.io", "192.0.2.1"); wait != 0 {
+		t.Fatalf("Check after Release = %v, want 0", wait)
+	}
+
+	// Un login correcto borra los fallos del email pero no los de la IP
+	lg.Succeed("ana@x.io", "192.0.2.1")
+	if _, ok := lg.attempts[emailKey("ana@x.io")]; ok {
+		t.Fatal("email attempts kept after Succeed")
+	}
+	if attempts := lg.attempts[ipKey("192.0.2.1")]; attempts == nil || attempts.failures != LOGIN_FREE_ATTEMPTS_EMAIL || attempts.pending != 0 {
+		t.Fatalf("ip attempts after Succeed = %+v, want %d failures and none pending", attempts, LOGIN_FREE_ATTEMPTS_EMAIL)
+	}
+	if lg.Unlock("ana@x.io") {
+		t.Fatal("Unlock = true for an email without failures")
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"afperdomo2/go/rest-ws/mailer"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	RESET_TOKEN_SIZE = 32 // Bytes aleatorios de cada token para restablecer la contraseña
+)
+
+// ErrInvalidResetToken indica que el token no existe, ya se usó o expiró
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordService contiene la lógica para restablecer una contraseña olvidada
+type PasswordService struct{}
+
+// RequestReset envía al usuario un email con un token de un solo uso para restablecer su contraseña
+// Si el email no está registrado no hace nada y retorna nil, para no revelar qué cuentas existen
+// Del token solo se guarda su hash: quien lea la base de datos no puede usarlo
+func (ps *PasswordService) RequestReset(ctx context.Context, s server.Server, email string) error {
+	user, err := repository.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateRandomToken(RESET_TOKEN_SIZE)
+	if err != nil {
+		return err
+	}
+	ttl := s.Config().PasswordResetTTL
+	err = repository.CreateUserToken(ctx, &models.UserToken{
+		UserId:    user.Id,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer().Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("We received a request to reset the password of your account.\n\n%s\n\n"+
+			"It expires in %s and can only be used once. If you did not request it, you can ignore this email.",
+			tokenInstructions(s.Config().PasswordResetURL, token, "choose a new password"), formatTTL(ttl)),
+	})
+}
+
+// tokenInstructions devuelve el enlace a la página del frontend con el token (?token=...)
+// o, si no hay página configurada, el token; action describe para qué sirve (ej: "choose a new password")
+func tokenInstructions(pageURL string, token string, action string) string {
+	link, err := url.Parse(pageURL)
+	if pageURL == "" || err != nil {
+		return "Use this token to " + action + ": " + token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return "Open this link to " + action + ":\n" + link.String()
+}
+
+// formatTTL expresa la validez de un token para el texto de un email (ej: "24 hours", "30 minutes")
+func formatTTL(ttl time.Duration) string {
+	switch {
+	case ttl == time.Hour:
+		return "1 hour"
+	case ttl > time.Hour && ttl%time.Hour == 0:
+		return fmt.Sprintf("%d hours", int(ttl.Hours()))
+	default:
+		return fmt.Sprintf("%d minutes", int(ttl.Minutes()))
+	}
+}
+
+// ResetPassword consume el token y cambia la contraseña del usuario
+// Después invalida el resto de tokens de restablecimiento del usuario y todas sus sesiones:
+// los refresh tokens se revocan y los access tokens emitidos antes del cambio dejan de aceptarse
+// (ver UserService.Authenticate)
+func (ps *PasswordService) ResetPassword(ctx context.Context, token string, password string) error {
+	stored, err := repository.ConsumeUserToken(ctx, models.TokenPurposePasswordReset, utils.HashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), PASSWORD_HASH_COST)
+	if err != nil {
+		return err
+	}
+	if err := repository.UpdateUserPassword(ctx, stored.UserId, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := repository.DeleteUserTokens(ctx, stored.UserId, models.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+	if err := TokenServiceInstance.RevokeAllSessions(ctx, stored.UserId); err != nil {
+		return err
+	}
+
+	// Quien recibe el email demuestra ser el titular: se levanta el bloqueo del login
+	user, err := repository.GetUserById(ctx, stored.UserId)
+	if err != nil {
+		return err
+	}
+	LoginGuardInstance.Unlock(user.Email)
+	return nil
+}
+
+// Instancia global del servicio (patrón Singleton simple)
+var PasswordServiceInstance = &PasswordService{}
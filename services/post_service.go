@@ -0,0 +1,34 @@
+package services
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"context"
+)
+
+// PostService contiene las reglas de negocio para modificar posts
+type PostService struct{}
+
+// canModifyAny indica si el usuario puede modificar posts de otros autores
+// El autor siempre puede modificar los suyos; moderadores y administradores, cualquiera
+func (ps *PostService) canModifyAny(user *models.User) bool {
+	return user.HasRole(models.RoleModerator, models.RoleAdmin)
+}
+
+// UpdatePost actualiza el título y contenido de un post si el usuario tiene permiso
+// El permiso se comprueba en la misma actualización (ver repository.UpdatePost):
+// retorna repository.ErrPostNotFound (404) o repository.ErrNotPostOwner (403)
+// Retorna el post con los cambios aplicados
+func (ps *PostService) UpdatePost(ctx context.Context, user *models.User, id int64, changes *models.Post) (*models.Post, error) {
+	return repository.UpdatePost(ctx, id, changes, user.Id, ps.canModifyAny(user))
+}
+
+// DeletePost borra un post si el usuario tiene permiso
+// El permiso se comprueba en el mismo borrado (ver repository.DeletePost)
+// Retorna el post borrado
+func (ps *PostService) DeletePost(ctx context.Context, user *models.User, id int64) (*models.Post, error) {
+	return repository.DeletePost(ctx, id, user.Id, ps.canModifyAny(user))
+}
+
+// Instancia global del servicio (patrón Singleton simple)
+var PostServiceInstance = &PostService{}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	REFRESH_TOKEN_SIZE = 32 // Bytes aleatorios de cada refresh token
+	JTI_SIZE           = 16 // Bytes aleatorios del identificador (jti) de cada access token
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// TokenPair agrupa el access token (JWT de corta duración) y el refresh token (opaco y rotativo)
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // Segundos de vida del access token
+}
+
+// TokenService contiene la lógica de emisión, rotación y revocación de tokens
+type TokenService struct{}
+
+// IssueTokens emite un nuevo access token firmado y un refresh token persistido para el usuario
+func (ts *TokenService) IssueTokens(ctx context.Context, s server.Server, user *models.User) (*TokenPair, error) {
+	jti, err := utils.GenerateRandomToken(JTI_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	accessTTL := s.Config().AccessTokenTTL
+	now := time.Now()
+	claims := &models.AppClaims{
+		UserId: user.Id,
+		Role:   user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Issuer:    s.Config().JWTIssuer,
+			Subject:   strconv.FormatInt(user.Id, 10),
+			Audience:  jwt.ClaimStrings{s.Config().JWTAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTTL)),
+		},
+	}
+	// Se firma con la clave activa; su kid viaja en el header del token
+	accessToken, err := s.Keys().Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(REFRESH_TOKEN_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserId:    user.Id,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.Config().RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTTL.Seconds()),
+	}, nil
+}
+
+// Refresh rota un refresh token: lo revoca y emite un nuevo par de tokens
+// Si se presenta un token ya revocado (posible robo), se revocan todas las sesiones del usuario
+func (ts *TokenService) Refresh(ctx context.Context, s server.Server, refreshToken string) (*models.User, *TokenPair, error) {
+	stored, err := repository.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	if stored.RevokedAt != nil {
+		// Reutilización de un token ya rotado: se invalida toda la familia de sesiones
+		if err := repository.RevokeUserRefreshTokens(ctx, stored.UserId); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if !stored.IsActive(time.Now()) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	// La revocación es atómica: si otra petición rotó el token primero, esta falla
+	if err := repository.RevokeRefreshToken(ctx, stored.Id); err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := repository.GetUserById(ctx, stored.UserId)
+	if err != nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := ts.IssueTokens(ctx, s, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, pair, nil
+}
+
+// Logout revoca el access token actual (por su jti) y, si se envía, el refresh token asociado
+func (ts *TokenService) Logout(ctx context.Context, claims *models.AppClaims, refreshToken string) error {
+	if err := repository.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	stored, err := repository.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil || stored.UserId != claims.UserId || stored.RevokedAt != nil {
+		return nil // Nada que revocar
+	}
+	return repository.RevokeRefreshToken(ctx, stored.Id)
+}
+
+// RevokeAllSessions revoca todos los refresh tokens del usuario
+// Debe usarse al cambiar la contraseña; los access tokens vigentes se rechazan
+// por su iat (ver UserService.Authenticate)
+func (ts *TokenService) RevokeAllSessions(ctx context.Context, userId int64) error {
+	return repository.RevokeUserRefreshTokens(ctx, userId)
+}
+
+// Instancia global del servicio (patrón Singleton simple)
+var TokenServiceInstance = &TokenService{}
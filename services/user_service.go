@@ -1,48 +1,84 @@
-package services
-
-import (
-	"afperdomo2/go/rest-ws/models"
-	"afperdomo2/go/rest-ws/repository"
-	"afperdomo2/go/rest-ws/server"
-	"afperdomo2/go/rest-ws/utils"
-	"context"
-	"errors"
-	"net/http"
-)
-
-var (
-	ErrUserNotFound = errors.New("user not found")
-)
-
-// UserService contiene la lógica de negocio relacionada con usuarios
-type UserService struct{}
-
-// GetUserFromToken combina las utilidades de JWT con la lógica de negocio
-// para obtener un usuario completo desde un token en la request
-func (us *UserService) GetUserFromToken(r *http.Request, s server.Server, w http.ResponseWriter) *models.User {
-	// Usar utilidad para extraer token
-	tokenString, err := utils.ExtractTokenFromRequest(r)
-	if err != nil {
-		http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-		return nil
-	}
-
-	// Usar utilidad para validar token y obtener claims
-	claims, err := utils.ParseAndValidateToken(tokenString, s.Config().JWTSecret)
-	if err != nil {
-		http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
-		return nil
-	}
-
-	// Lógica de negocio: obtener usuario de la base de datos
-	user, err := repository.GetUserById(context.Background(), claims.UserId)
-	if err != nil {
-		http.Error(w, "User not found: "+err.Error(), http.StatusNotFound)
-		return nil
-	}
-
-	return user
-}
-
-// Instancia global del servicio (patrón Singleton simple)
-var UserServiceInstance = &UserService{}
+package services
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnauthenticated indica que el token no es válido o que su usuario ya no existe
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrInvalidCredentials indica que el email no existe o que la contraseña no coincide
+// Ambos casos se reportan igual para no revelar qué cuentas existen
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// PASSWORD_HASH_COST es el costo de bcrypt de las contraseñas almacenadas
+const PASSWORD_HASH_COST = 8
+
+// dummyPasswordHash se compara cuando el email no existe, para que el login tarde lo mismo
+// que con un email registrado y el tiempo de respuesta no revele qué cuentas existen
+// Se calcula al iniciar para que el primer login no tarde más que los siguientes
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), PASSWORD_HASH_COST)
+
+// UserService contiene la lógica de negocio relacionada con usuarios
+type UserService struct{}
+
+// Authenticate combina las utilidades de JWT con la lógica de negocio:
+// valida el token una sola vez y carga el usuario al que pertenece
+// Los errores de autenticación se envuelven en ErrUnauthenticated; cualquier otro error
+// (p. ej. la base de datos no responde) se devuelve tal cual para que se mapee a su código HTTP
+func (us *UserService) Authenticate(ctx context.Context, tokenString string, options utils.TokenOptions) (*models.AppClaims, *models.User, error) {
+	claims, err := utils.ParseAndValidateToken(ctx, tokenString, options)
+	if errors.Is(err, utils.ErrInvalidToken) || errors.Is(err, utils.ErrInvalidClaims) || errors.Is(err, utils.ErrTokenRevoked) {
+		return nil, nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Lógica de negocio: el usuario del token debe seguir existiendo
+	user, err := repository.GetUserById(ctx, claims.UserId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Cambiar la contraseña invalida los access tokens emitidos antes del cambio
+	// iat tiene precisión de segundos: se compara con el segundo del cambio
+	if user.PasswordChangedAt != nil && claims.IssuedAt != nil && claims.IssuedAt.Before(user.PasswordChangedAt.Truncate(time.Second)) {
+		return nil, nil, fmt.Errorf("%w: %w", ErrUnauthenticated, utils.ErrTokenRevoked)
+	}
+
+	return claims, user, nil
+}
+
+// Login verifica las credenciales y devuelve el usuario
+// Retorna ErrInvalidCredentials tanto si el email no existe como si la contraseña no coincide;
+// en ambos casos se ejecuta una comparación bcrypt, de modo que el tiempo de respuesta es el mismo
+func (us *UserService) Login(ctx context.Context, email string, password string) (*models.User, error) {
+	user, err := repository.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// Instancia global del servicio (patrón Singleton simple)
+var UserServiceInstance = &UserService{}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"afperdomo2/go/rest-ws/mailer"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/server"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	VERIFICATION_TOKEN_SIZE = 32 // Bytes aleatorios de cada token de verificación de email
+)
+
+var (
+	// ErrInvalidVerificationToken indica que el token no existe, ya se usó o expiró
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	// ErrAlreadyVerified indica que el usuario ya confirmó su email
+	ErrAlreadyVerified = errors.New("email already verified")
+)
+
+// VerificationService contiene la lógica para confirmar que el email de un usuario le pertenece
+type VerificationService struct{}
+
+// SendVerification envía al usuario un email con un token de un solo uso para confirmar su email
+// Los tokens enviados antes dejan de ser válidos: solo sirve el enlace del último email
+func (vs *VerificationService) SendVerification(ctx context.Context, s server.Server, user *models.User) error {
+	if user.IsVerified() {
+		return ErrAlreadyVerified
+	}
+
+	token, err := utils.GenerateRandomToken(VERIFICATION_TOKEN_SIZE)
+	if err != nil {
+		return err
+	}
+	if err := repository.DeleteUserTokens(ctx, user.Id, models.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+	ttl := s.Config().EmailVerificationTTL
+	err = repository.CreateUserToken(ctx, &models.UserToken{
+		UserId:    user.Id,
+		Purpose:   models.TokenPurposeEmailVerification,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer().Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Welcome! Please confirm that this email address belongs to you.\n\n%s\n\n"+
+			"It expires in %s and can only be used once. If you did not create an account, you can ignore this email.",
+			tokenInstructions(s.Config().EmailVerificationURL, token, "confirm your email"), formatTTL(ttl)),
+	})
+}
+
+// Verify consume el token y marca el email del usuario como verificado
+// Retorna el usuario verificado
+func (vs *VerificationService) Verify(ctx context.Context, token string) (*models.User, error) {
+	stored, err := repository.ConsumeUserToken(ctx, models.TokenPurposeEmailVerification, utils.HashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repository.MarkUserVerified(ctx, stored.UserId); err != nil {
+		return nil, err
+	}
+	if err := repository.DeleteUserTokens(ctx, stored.UserId, models.TokenPurposeEmailVerification); err != nil {
+		return nil, err
+	}
+	return repository.GetUserById(ctx, stored.UserId)
+}
+
+// Instancia global del servicio (patrón Singleton simple)
+var VerificationServiceInstance = &VerificationService{}
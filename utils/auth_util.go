@@ -1,44 +1,151 @@
-package utils
-
-import (
-	"afperdomo2/go/rest-ws/models"
-	"errors"
-	"net/http"
-	"strings"
-
-	"github.com/golang-jwt/jwt"
-)
-
-var (
-	ErrMissingAuthHeader = errors.New("missing Authorization header")
-	ErrInvalidToken      = errors.New("invalid token")
-	ErrInvalidClaims     = errors.New("invalid token claims")
-)
-
-// ExtractTokenFromRequest extrae el token JWT del header Authorization de la request
-// Esta es una función utilitaria pura que solo se encarga de la extracción
-func ExtractTokenFromRequest(r *http.Request) (string, error) {
-	tokenString := strings.TrimSpace(r.Header.Get("Authorization"))
-	if tokenString == "" {
-		return "", ErrMissingAuthHeader
-	}
-	return tokenString, nil
-}
-
-// ParseAndValidateToken parsea y valida un token JWT, devolviendo los claims
-// Esta función es pura y no tiene efectos secundarios
-func ParseAndValidateToken(tokenString string, jwtSecret string) (*models.AppClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &models.AppClaims{}, func(token *jwt.Token) (any, error) {
-		return []byte(jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return nil, ErrInvalidToken
-	}
-
-	if claims, ok := token.Claims.(*models.AppClaims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, ErrInvalidClaims
-}
+package utils
+
+import (
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrMissingAuthHeader = errors.New("missing access token")
+	ErrInvalidAuthScheme = errors.New("unsupported authorization scheme")
+	ErrInvalidToken      = errors.New("invalid token")
+	ErrInvalidClaims     = errors.New("invalid token claims")
+	ErrTokenRevoked      = errors.New("token has been revoked")
+)
+
+const (
+	AccessTokenCookie = "access_token" // Cookie HttpOnly con el access token (clientes de navegador)
+	TokenQueryParam   = "token"        // Parámetro de la URL con el access token (solo en /ws)
+)
+
+// ExtractTokenFromRequest extrae el token JWT de la request
+// Esta es una función utilitaria pura que solo se encarga de la extracción
+// Orden de precedencia:
+//  1. Header Authorization con el esquema Bearer (RFC 6750): "Authorization: Bearer <token>"
+//  2. Cookie HttpOnly AccessTokenCookie
+//
+// Si el header Authorization está presente se usa siempre, aunque también exista la cookie
+func ExtractTokenFromRequest(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return parseAuthorizationHeader(header)
+	}
+	if cookie, err := r.Cookie(AccessTokenCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	return "", ErrMissingAuthHeader
+}
+
+// ExtractWebSocketToken extrae el token JWT de la request de upgrade de /ws
+// Los navegadores no permiten enviar headers en el handshake, por lo que además se acepta
+// el token en la URL (?token=<jwt>). Orden de precedencia:
+//  1. Header Authorization (Bearer)
+//  2. Parámetro TokenQueryParam de la URL
+//  3. Cookie HttpOnly AccessTokenCookie
+func ExtractWebSocketToken(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return parseAuthorizationHeader(header)
+	}
+	if token := r.URL.Query().Get(TokenQueryParam); token != "" {
+		return token, nil
+	}
+	return ExtractTokenFromRequest(r)
+}
+
+// parseAuthorizationHeader obtiene el token del header Authorization
+// El esquema Bearer no distingue mayúsculas; por compatibilidad con los clientes existentes
+// también se acepta el token sin esquema. Cualquier otro esquema (p. ej. Basic) se rechaza
+func parseAuthorizationHeader(header string) (string, error) {
+	header = strings.TrimSpace(header)
+	scheme, token, found := strings.Cut(header, " ")
+	if !found {
+		if strings.EqualFold(header, "Bearer") { // Esquema sin token
+			return "", ErrInvalidAuthScheme
+		}
+		return header, nil
+	}
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrInvalidAuthScheme
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrInvalidAuthScheme
+	}
+	return token, nil
+}
+
+// SetAccessTokenCookie guarda el access token en una cookie HttpOnly para los clientes de navegador
+// SameSite=Strict evita que la cookie viaje en requests iniciadas desde otros sitios (CSRF)
+func SetAccessTokenCookie(w http.ResponseWriter, token string, ttl time.Duration, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AccessTokenCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearAccessTokenCookie elimina la cookie del access token (logout)
+func ClearAccessTokenCookie(w http.ResponseWriter, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AccessTokenCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// TokenOptions agrupa las claves y las reglas de validación de los access tokens
+type TokenOptions struct {
+	Keys     *KeySet       // Claves de verificación (por kid)
+	Issuer   string        // Valor esperado del claim iss
+	Audience string        // Valor que debe incluir el claim aud
+	Leeway   time.Duration // Tolerancia de desfase de reloj para exp, nbf e iat
+}
+
+// ParseAndValidateToken parsea y valida un token JWT, devolviendo los claims
+// La firma se verifica con la clave del KeySet indicada por el kid del token
+// Se exigen exp, iat y jti, y que iss y aud correspondan a este entorno, de modo que se rechazan
+// los tokens emitidos para otro entorno; exp, nbf e iat se comparan con la tolerancia Leeway
+// Además verifica que el token (por su jti) no haya sido revocado
+func ParseAndValidateToken(ctx context.Context, tokenString string, options TokenOptions) (*models.AppClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &models.AppClaims{}, options.Keys.Keyfunc,
+		jwt.WithIssuer(options.Issuer),
+		jwt.WithAudience(options.Audience),
+		jwt.WithLeeway(options.Leeway),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(*models.AppClaims)
+	if !ok || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, ErrInvalidClaims
+	}
+
+	// Un token revocado (logout) deja de ser válido aunque no haya expirado
+	revoked, err := repository.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthorizationHeader(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		token  string
+		err    error
+	}{
+		{"bearer", "Bearer abc.def.ghi", "abc.def.ghi", nil},
+		{"lowercase scheme", "bearer abc.def.ghi", "abc.def.ghi", nil},
+		{"uppercase scheme", "BEARER abc.def.ghi", "abc.def.ghi", nil},
+		{"extra spaces", "  Bearer    abc.def.ghi  ", "abc.def.ghi", nil},
+		{"raw token without scheme", "abc.def.ghi", "abc.def.ghi", nil},
+		{"basic scheme", "Basic dXNlcjpwYXNz", "", ErrInvalidAuthScheme},
+		{"scheme without token", "Bearer", "", ErrInvalidAuthScheme},
+		{"scheme with blank token", "Bearer \t ", "", ErrInvalidAuthScheme},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := parseAuthorizationHeader(tc.header)
+			if token != tc.token || !errors.Is(err, tc.err) {
+				t.Fatalf("parseAuthorizationHeader(%q) = %q, %v; want %q, %v", tc.header, token, err, tc.token, tc.err)
+			}
+		})
+	}
+}
+
+func TestExtractTokenPrecedence(t *testing.T) {
+	cases := []struct {
+		name      string
+		header    string
+		query     string
+		cookie    string
+		websocket bool
+		token     string
+		err       error
+	}{
+		{"header over cookie", "Bearer from-header", "", "from-cookie", false, "from-header", nil},
+		{"cookie", "", "", "from-cookie", false, "from-cookie", nil},
+		{"query ignored outside websocket", "", "from-query", "", false, "", ErrMissingAuthHeader},
+		{"nothing", "", "", "", false, "", ErrMissingAuthHeader},
+		{"invalid header is not skipped", "Basic abc", "", "from-cookie", false, "", ErrInvalidAuthScheme},
+		{"ws header over query", "Bearer from-header", "from-query", "from-cookie", true, "from-header", nil},
+		{"ws query over cookie", "", "from-query", "from-cookie", true, "from-query", nil},
+		{"ws cookie", "", "", "from-cookie", true, "from-cookie", nil},
+		{"ws invalid header is not skipped", "Basic abc", "from-query", "", true, "", ErrInvalidAuthScheme},
+		{"ws nothing", "", "", "", true, "", ErrMissingAuthHeader},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/ws"
+			if tc.query != "" {
+				target += "?" + TokenQueryParam + "=" + tc.query
+			}
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: AccessTokenCookie, Value: tc.cookie})
+			}
+
+			extract := ExtractTokenFromRequest
+			if tc.websocket {
+				extract = ExtractWebSocketToken
+			}
+			token, err := extract(r)
+			if token != tc.token || !errors.Is(err, tc.err) {
+				t.Fatalf("token = %q, %v; want %q, %v", token, err, tc.token, tc.err)
+			}
+		})
+	}
+}
+
+func TestWriteUnauthorized(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		challenge string
+	}{
+		{"missing credentials", nil, `Bearer realm="rest-ws"`},
+		{"missing header", ErrMissingAuthHeader, `Bearer realm="rest-ws"`},
+		{"invalid scheme", ErrInvalidAuthScheme, `error="invalid_request"`},
+		{"invalid token", ErrInvalidToken, `error="invalid_token"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteUnauthorizedMessage(rec, tc.err, "Invalid email or password")
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, `Bearer realm="rest-ws"`) || !strings.Contains(challenge, tc.challenge) {
+				t.Fatalf("WWW-Authenticate = %q, want it to contain %q", challenge, tc.challenge)
+			}
+			if !strings.Contains(rec.Body.String(), "Invalid email or password") {
+				t.Fatalf("body = %s, want the custom message", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// trustedProxies son las redes de los proxies (balanceadores, ingress) cuyo X-Forwarded-For se acepta
+var (
+	trustedProxiesMutex sync.RWMutex
+	trustedProxies      []netip.Prefix
+)
+
+// SetTrustedProxies configura los proxies de confianza: IPs (10.0.0.1) o redes CIDR (10.0.0.0/8)
+// Sin proxies de confianza se ignora X-Forwarded-For, que cualquier cliente puede falsificar
+func SetTrustedProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		var prefix netip.Prefix
+		var err error
+		if strings.Contains(proxy, "/") {
+			prefix, err = netip.ParsePrefix(proxy)
+		} else {
+			var addr netip.Addr
+			addr, err = netip.ParseAddr(proxy)
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	trustedProxiesMutex.Lock()
+	defer trustedProxiesMutex.Unlock()
+	trustedProxies = prefixes
+	return nil
+}
+
+// isTrustedProxy indica si la IP pertenece a alguno de los proxies de confianza
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap() // ::ffff:10.0.0.1 -> 10.0.0.1
+
+	trustedProxiesMutex.RLock()
+	defer trustedProxiesMutex.RUnlock()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP devuelve la IP del cliente (sin el puerto)
+// Si la conexión llega desde un proxy de confianza, recorre X-Forwarded-For de derecha a izquierda
+// (cada proxy añade al final la IP de quien le habló) y devuelve la primera IP que no sea
+// de un proxy de confianza; las entradas más a la izquierda las escribe el cliente y no son fiables
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break // Entrada inválida: no se puede seguir confiando en la cadena
+		}
+		ip = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
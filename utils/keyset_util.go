@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256" // HMAC con el secreto compartido JWT_SECRET
+	AlgRS256 = "RS256" // RSA PKCS#1 v1.5 con SHA-256
+	AlgEdDSA = "EdDSA" // Ed25519
+
+	rsaKeyBits = 2048 // Tamaño de las claves RSA generadas
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrNoActiveKey    = errors.New("no active signing key")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+	ErrVerifyOnlyKey  = errors.New("key can only verify tokens")
+)
+
+// SigningKey es una clave para firmar y/o verificar JWT, identificada por su kid
+// Las claves de las que solo se conoce la parte pública (p. ej. claves retiradas) solo verifican
+type SigningKey struct {
+	Kid    string
+	Method jwt.SigningMethod
+	sign   any // Clave privada (o secreto HMAC); nil si la clave solo verifica
+	verify any // Clave pública (o secreto HMAC)
+}
+
+// CanSign indica si la clave puede firmar tokens
+func (k *SigningKey) CanSign() bool {
+	return k.sign != nil
+}
+
+// NewHMACKey crea la clave simétrica HS256 a partir del secreto compartido
+func NewHMACKey(kid string, secret string) *SigningKey {
+	return &SigningKey{Kid: kid, Method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}
+}
+
+// GenerateSigningKey genera una nueva clave asimétrica para el algoritmo indicado (RS256 o EdDSA)
+func GenerateSigningKey(kid string, alg string) (*SigningKey, error) {
+	switch alg {
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodRS256, sign: private, verify: &private.PublicKey}, nil
+	case AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, sign: private, verify: public}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+}
+
+// ParseSigningKeyPEM interpreta una clave en formato PEM:
+// privada PKCS#8 o PKCS#1 (RSA), o pública PKIX o PKCS#1 (RSA)
+// El algoritmo se deduce del tipo de clave: RSA firma con RS256 y Ed25519 con EdDSA
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: invalid PEM data", kid)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: %w: PEM block %q", kid, ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kid, err)
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodRS256, sign: key, verify: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodRS256, verify: key}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, sign: key, verify: key.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, verify: key}, nil
+	default:
+		return nil, fmt.Errorf("key %q: %w: %T", kid, ErrUnsupportedKey, parsed)
+	}
+}
+
+// LoadSigningKeysDir carga todas las claves *.pem de un directorio, ordenadas por nombre
+// El kid de cada clave es el nombre del archivo sin la extensión (ej: 2025-01.pem -> "2025-01")
+func LoadSigningKeysDir(dir string) ([]*SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParseSigningKeyPEM(kid, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeySet es el conjunto de claves con las que se firman y verifican los access tokens
+// Solo la clave activa firma; todas las demás siguen verificando, de modo que rotar
+// la clave activa no invalida los tokens ya emitidos
+type KeySet struct {
+	mutex  sync.RWMutex
+	keys   map[string]*SigningKey
+	order  []string // kids en orden de registro (para publicar el JWKS de forma estable)
+	active string
+}
+
+// NewKeySet crea un conjunto de claves vacío
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]*SigningKey)}
+}
+
+// Add registra una clave (o reemplaza la que tenga el mismo kid)
+func (ks *KeySet) Add(key *SigningKey) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	if _, exists := ks.keys[key.Kid]; !exists {
+		ks.order = append(ks.order, key.Kid)
+	}
+	ks.keys[key.Kid] = key
+}
+
+// SetActive elige la clave con la que se firman los nuevos tokens
+func (ks *KeySet) SetActive(kid string) error {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	key, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("%w: %q", ErrVerifyOnlyKey, kid)
+	}
+	ks.active = kid
+	return nil
+}
+
+// Active devuelve la clave con la que se firman los nuevos tokens
+func (ks *KeySet) Active() (*SigningKey, error) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	key, ok := ks.keys[ks.active]
+	if !ok {
+		return nil, ErrNoActiveKey
+	}
+	return key, nil
+}
+
+// Keys devuelve las claves registradas, en orden de registro
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	keys := make([]*SigningKey, 0, len(ks.order))
+	for _, kid := range ks.order {
+		keys = append(keys, ks.keys[kid])
+	}
+	return keys
+}
+
+// Sign firma los claims con la clave activa e incluye su kid en el header del token
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.Active()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.Kid != "" {
+		token.Header["kid"] = key.Kid
+	}
+	return token.SignedString(key.sign)
+}
+
+// Keyfunc resuelve la clave de verificación de un token a partir de su kid
+// El algoritmo del token debe coincidir con el de la clave, para evitar ataques de confusión
+// de algoritmo (p. ej. un token HS256 firmado con la clave pública RSA)
+// Los tokens sin kid se verifican con la clave HMAC sin kid (tokens emitidos antes de la rotación)
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	ks.mutex.RLock()
+	key, ok := ks.keys[kid]
+	ks.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+// JWK es la representación pública de una clave según RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA: módulo
+	E   string `json:"e,omitempty"`   // RSA: exponente
+	Crv string `json:"crv,omitempty"` // OKP: curva
+	X   string `json:"x,omitempty"`   // OKP: clave pública
+}
+
+// JWKS es el documento publicado en /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS devuelve las claves públicas del conjunto, para que otros servicios verifiquen los tokens
+// Las claves HMAC nunca se publican
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0)}
+	for _, key := range ks.Keys() {
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.Kid,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.Kid,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestKey genera una clave asimétrica o termina el test
+func newTestKey(t *testing.T, kid, alg string) *SigningKey {
+	t.Helper()
+	key, err := GenerateSigningKey(kid, alg)
+	if err != nil {
+		t.Fatalf("GenerateSigningKey(%s): %v", alg, err)
+	}
+	return key
+}
+
+// verify verifica la firma de un token con el KeySet (sin validar los claims)
+func verify(ks *KeySet, token string) error {
+	_, err := jwt.Parse(token, ks.Keyfunc, jwt.WithoutClaimsValidation())
+	return err
+}
+
+// kidOf devuelve el kid del header de un token
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestKeySetRotation(t *testing.T) {
+	ks := NewKeySet()
+	ks.Add(newTestKey(t, "2025-01", AlgRS256))
+	if err := ks.SetActive("2025-01"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	claims := jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	oldToken, err := ks.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// Rotación: la clave nueva firma y la anterior sigue verificando
+	ks.Add(newTestKey(t, "2025-02", AlgEdDSA))
+	if err := ks.SetActive("2025-02"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	newToken, err := ks.Sign(claims)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if kid := kidOf(t, oldToken); kid != "2025-01" {
+		t.Errorf("old token kid = %q, want 2025-01", kid)
+	}
+	if kid := kidOf(t, newToken); kid != "2025-02" {
+		t.Errorf("new token kid = %q, want 2025-02", kid)
+	}
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if err := verify(ks, token); err != nil {
+			t.Errorf("%s token after rotation: %v", name, err)
+		}
+	}
+
+	// Un token firmado con una clave ajena al conjunto no se acepta, tenga el kid que tenga
+	foreign := NewKeySet()
+	foreign.Add(newTestKey(t, "2025-03", AlgRS256))
+	foreign.SetActive("2025-03")
+	unknown, _ := foreign.Sign(claims)
+	if err := verify(ks, unknown); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("token with an unknown kid: error = %v, want ErrUnknownKey", err)
+	}
+	spoofed := newTestKey(t, "2025-01", AlgRS256)
+	impostor := NewKeySet()
+	impostor.Add(spoofed)
+	impostor.SetActive("2025-01")
+	forged, _ := impostor.Sign(claims)
+	if err := verify(ks, forged); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("token with a known kid but another key: error = %v, want ErrTokenSignatureInvalid", err)
+	}
+
+	if err := ks.SetActive("2025-03"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("SetActive with an unknown kid = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeySetRetiredKey(t *testing.T) {
+	retired := newTestKey(t, "2024-12", AlgRS256)
+	signer := NewKeySet()
+	signer.Add(retired)
+	signer.SetActive("2024-12")
+	token, err := signer.Sign(jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// Una clave retirada se publica solo con su parte pública: verifica, pero no puede firmar
+	der, err := x509.MarshalPKIXPublicKey(retired.verify)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	public, err := ParseSigningKeyPEM("2024-12", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseSigningKeyPEM: %v", err)
+	}
+	ks := NewKeySet()
+	ks.Add(public)
+	if err := ks.SetActive("2024-12"); !errors.Is(err, ErrVerifyOnlyKey) {
+		t.Fatalf("SetActive with a verify-only key = %v, want ErrVerifyOnlyKey", err)
+	}
+	if _, err := ks.Sign(jwt.RegisteredClaims{}); !errors.Is(err, ErrNoActiveKey) {
+		t.Fatalf("Sign without an active key = %v, want ErrNoActiveKey", err)
+	}
+	if err := verify(ks, token); err != nil {
+		t.Fatalf("token signed by the retired key: %v", err)
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	ks := NewKeySet()
+	ks.Add(NewHMACKey("", "super-secret-hmac"))
+	ks.Add(NewHMACKey("hmac-2", "another-secret"))
+	ks.Add(newTestKey(t, "rsa-1", AlgRS256))
+	ks.Add(newTestKey(t, "ed-1", AlgEdDSA))
+
+	set := ks.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want the 2 asymmetric ones", len(set.Keys))
+	}
+	if rsa := set.Keys[0]; rsa.Kid != "rsa-1" || rsa.Kty != "RSA" || rsa.Alg != AlgRS256 || rsa.Use != "sig" || rsa.N == "" || rsa.E != "AQAB" {
+		t.Errorf("RSA JWK = %+v", rsa)
+	}
+	if ed := set.Keys[1]; ed.Kid != "ed-1" || ed.Kty != "OKP" || ed.Alg != AlgEdDSA || ed.Crv != "Ed25519" || ed.X == "" {
+		t.Errorf("Ed25519 JWK = %+v", ed)
+	}
+
+	// Ni los secretos HMAC ni ningún material privado aparecen en el documento publicado
+	data, _ := json.Marshal(set)
+	for _, leak := range []string{"super-secret-hmac", "another-secret", `"oct"`, `"k"`, `"d"`, `"p"`, `"q"`} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("JWKS contains %s: %s", leak, data)
+		}
+	}
+}
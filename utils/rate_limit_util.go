@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitResult es la decisión del limitador para una request
+type RateLimitResult struct {
+	Allowed    bool          // La request puede continuar
+	Limit      int           // Requests permitidas por ventana (capacidad del bucket)
+	Window     time.Duration // Ventana en la que se recupera la capacidad completa
+	Remaining  int           // Requests que aún se pueden hacer sin esperar
+	Reset      time.Duration // Tiempo hasta recuperar la capacidad completa
+	RetryAfter time.Duration // Tiempo hasta poder hacer la siguiente request (0 si Allowed)
+}
+
+// RateLimiter decide si una request identificada por key puede continuar
+// TokenBucketLimiter guarda los buckets en memoria; otra implementación (p. ej. en Redis)
+// permitiría compartir los límites entre varias instancias de la API
+type RateLimiter interface {
+	Allow(key string) RateLimitResult
+}
+
+// tokenBucket es el estado de una clave: tokens disponibles y cuándo se recalcularon
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// TokenBucketLimiter implementa RateLimiter con el algoritmo token bucket:
+// cada clave empieza con limit tokens, cada request consume uno y se recuperan
+// de forma continua a razón de limit por window (ráfagas de hasta limit requests)
+type TokenBucketLimiter struct {
+	limit     int
+	window    time.Duration
+	rate      float64 // Tokens recuperados por segundo
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time // Reloj; los tests lo sustituyen para no depender del tiempo real
+}
+
+// NewTokenBucketLimiter crea un limitador de limit requests por window para cada clave
+func NewTokenBucketLimiter(limit int, window time.Duration) *TokenBucketLimiter {
+	if limit <= 0 || window <= 0 {
+		panic("utils.NewTokenBucketLimiter: limit and window must be positive")
+	}
+	return &TokenBucketLimiter{
+		limit:   limit,
+		window:  window,
+		rate:    float64(limit) / window.Seconds(),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow consume un token del bucket de la clave, si hay alguno disponible
+func (l *TokenBucketLimiter) Allow(key string) RateLimitResult {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit), updated: now}
+		l.buckets[key] = bucket
+	} else {
+		elapsed := now.Sub(bucket.updated).Seconds()
+		bucket.tokens = min(float64(l.limit), bucket.tokens+elapsed*l.rate)
+		bucket.updated = now
+	}
+
+	result := RateLimitResult{Limit: l.limit, Window: l.window}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - bucket.tokens)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = l.duration(float64(l.limit) - bucket.tokens)
+	return result
+}
+
+// duration es el tiempo que tarda el bucket en recuperar esa cantidad de tokens
+func (l *TokenBucketLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep elimina, como mucho una vez por ventana, los buckets que ya se han llenado
+// (equivalen a una clave nueva) para que el mapa no crezca sin límite. Debe llamarse con el mutex tomado
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= float64(l.limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
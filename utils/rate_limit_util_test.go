@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock es un reloj que solo avanza cuando el test lo indica
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time              { return c.now }
+func (c *fakeClock) Advance(delta time.Duration) { c.now = c.now.Add(delta) }
+
+func newTestLimiter(limit int, window time.Duration) (*TokenBucketLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewTokenBucketLimiter(limit, window)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	limiter, clock := newTestLimiter(3, 3*time.Second) // Un token por segundo
+
+	steps := []struct {
+		name       string
+		advance    time.Duration
+		key        string
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{"burst 1", 0, "a", true, 2, time.Second, 0},
+		{"burst 2", 0, "a", true, 1, 2 * time.Second, 0},
+		{"burst 3", 0, "a", true, 0, 3 * time.Second, 0},
+		{"exhausted", 0, "a", false, 0, 3 * time.Second, time.Second},
+		{"partial refill", 500 * time.Millisecond, "a", false, 0, 2500 * time.Millisecond, 500 * time.Millisecond},
+		{"one token back", 500 * time.Millisecond, "a", true, 0, 3 * time.Second, 0},
+		{"other key", 0, "b", true, 2, time.Second, 0},
+		{"full refill", 10 * time.Second, "a", true, 2, time.Second, 0},
+	}
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		got := limiter.Allow(step.key)
+		want := RateLimitResult{
+			Allowed:    step.allowed,
+			Limit:      3,
+			Window:     3 * time.Second,
+			Remaining:  step.remaining,
+			Reset:      step.reset,
+			RetryAfter: step.retryAfter,
+		}
+		if got != want {
+			t.Errorf("%s: Allow(%q) = %+v, want %+v", step.name, step.key, got, want)
+		}
+	}
+}
+
+func TestTokenBucketLimiterSweep(t *testing.T) {
+	limiter, clock := newTestLimiter(2, time.Minute)
+	limiter.Allow("full")
+	limiter.Allow("empty")
+	limiter.Allow("empty")
+
+	// Pasada la ventana, "full" ya ha recuperado todos sus tokens; "empty" aún no
+	clock.Advance(40 * time.Second)
+	limiter.Allow("empty")
+	clock.Advance(30 * time.Second)
+	limiter.Allow("trigger")
+
+	if _, ok := limiter.buckets["full"]; ok {
+		t.Error("bucket that refilled was not swept")
+	}
+	if _, ok := limiter.buckets["empty"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
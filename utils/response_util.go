@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"afperdomo2/go/rest-ws/repository"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorResponse es el cuerpo JSON de todas las respuestas de error de la API
+//
+//	{"error": {"code": "not_found", "message": "post not found"}}
+//
+// Los errores de validación (422) incluyen además el detalle de cada campo inválido:
+//
+//	{"error": {"code": "validation_failed", "message": "validation failed", "fields": [{"field": "email", "code": "email", "message": "must be a valid email address"}]}}
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describe un error: un código estable para máquinas y un mensaje para personas
+type ErrorDetail struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"` // Solo en los errores de validación
+}
+
+// errorCodes asocia cada código de estado HTTP con el código de error del cuerpo JSON
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusUnprocessableEntity: "validation_failed",
+	http.StatusTooManyRequests:     "too_many_requests",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// WriteJSON responde con el código de estado indicado y el valor codificado como JSON
+func WriteJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// WriteError responde con el cuerpo de error estándar de la API
+func WriteError(w http.ResponseWriter, status int, message string) {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = "error"
+	}
+	WriteJSON(w, status, ErrorResponse{
+		Error: ErrorDetail{Code: code, Message: message},
+	})
+}
+
+// WriteValidationError responde 422 con la lista de campos inválidos
+func WriteValidationError(w http.ResponseWriter, fields []FieldError) {
+	WriteJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+		Error: ErrorDetail{
+			Code:    errorCodes[http.StatusUnprocessableEntity],
+			Message: "validation failed",
+			Fields:  fields,
+		},
+	})
+}
+
+// WriteTooManyRequests responde 429 con el header Retry-After: los segundos (redondeados
+// hacia arriba) que el cliente debe esperar antes de reintentar
+func WriteTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+	WriteError(w, http.StatusTooManyRequests, message)
+}
+
+// AuthRealm es el realm anunciado en el header WWW-Authenticate
+const AuthRealm = "rest-ws"
+
+// WriteUnauthorized responde 401 con el desafío WWW-Authenticate del esquema Bearer (RFC 6750)
+// Sin credenciales solo se anuncia el esquema; con un header mal formado se indica invalid_request
+// y con un token inválido, expirado o revocado se indica invalid_token
+func WriteUnauthorized(w http.ResponseWriter, err error) {
+	WriteUnauthorizedMessage(w, err, "Unauthorized")
+}
+
+// WriteUnauthorizedMessage es WriteUnauthorized con un mensaje propio en el body
+// (ej: "Invalid email or password"); todos los 401 llevan el header WWW-Authenticate
+func WriteUnauthorizedMessage(w http.ResponseWriter, err error, message string) {
+	challenge := `Bearer realm="` + AuthRealm + `"`
+	switch {
+	case err == nil || errors.Is(err, ErrMissingAuthHeader):
+	case errors.Is(err, ErrInvalidAuthScheme):
+		challenge += `, error="invalid_request", error_description="Use the Bearer authorization scheme"`
+	default:
+		challenge += `, error="invalid_token", error_description="The access token is invalid, expired or revoked"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	WriteError(w, http.StatusUnauthorized, message)
+}
+
+// StatusFromError traduce un error de dominio del repositorio a su código de estado HTTP
+func StatusFromError(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrValidation):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WriteDomainError es el punto central para responder errores de dominio
+// Los errores desconocidos se registran en el log (con el request ID de r) y se responden
+// como 500 sin exponer su detalle
+func WriteDomainError(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusFromError(err)
+	if status == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "internal error", "error", err)
+		WriteError(w, status, "internal server error")
+		return
+	}
+	WriteError(w, status, err.Error())
+}
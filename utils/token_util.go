@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateRandomToken genera un token opaco aleatorio de size bytes
+// codificado en base64 URL-safe, apto para enviarse en JSON o en la URL
+func GenerateRandomToken(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken calcula el hash SHA-256 (en hexadecimal) de un token opaco
+// Es el valor que se persiste, de modo que una filtración de la base de datos no exponga tokens válidos
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
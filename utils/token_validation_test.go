@@ -0,0 +1,168 @@
+package utils_test
+
+// Tests externos al paquete: ParseAndValidateToken consulta las revocaciones en el repositorio,
+// y el repositorio en memoria (database) no se puede importar desde el paquete utils
+
+import (
+	"afperdomo2/go/rest-ws/database"
+	"afperdomo2/go/rest-ws/models"
+	"afperdomo2/go/rest-ws/repository"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testTokenOptions crea un KeySet con una clave RS256 activa y la clave HMAC sin kid de los
+// tokens anteriores a la rotación, y usa el repositorio en memoria para las revocaciones.
+// Devuelve también la clave privada RSA, para firmar tokens con claims arbitrarios
+func testTokenOptions(t *testing.T) (utils.TokenOptions, *rsa.PrivateKey) {
+	t.Helper()
+	repository.SetRepository(database.NewMemoryRepository())
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	rsaKey, err := utils.ParseSigningKeyPEM("rsa-1", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseSigningKeyPEM: %v", err)
+	}
+
+	keys := utils.NewKeySet()
+	keys.Add(utils.NewHMACKey("", "legacy-secret"))
+	keys.Add(rsaKey)
+	if err := keys.SetActive("rsa-1"); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	return utils.TokenOptions{Keys: keys, Issuer: "rest-ws", Audience: "rest-ws-api", Leeway: 30 * time.Second}, private
+}
+
+// signWith firma los claims con el método, kid y clave indicados (kid vacío = sin kid)
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func TestParseAndValidateToken(t *testing.T) {
+	options, private := testTokenOptions(t)
+	now := time.Now()
+
+	// claims devuelve unos claims válidos, modificados por change
+	claims := func(change func(*models.AppClaims)) *models.AppClaims {
+		c := &models.AppClaims{
+			UserId: 1,
+			Role:   models.RoleUser,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti-1",
+				Issuer:    "rest-ws",
+				Audience:  jwt.ClaimStrings{"rest-ws-api"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+	rs256 := func(change func(*models.AppClaims)) string {
+		return signWith(t, jwt.SigningMethodRS256, "rsa-1", private, claims(change))
+	}
+
+	// Ataque de confusión de algoritmo: HS256 con la clave pública RSA como secreto HMAC
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	cases := []struct {
+		name  string
+		token string
+		err   error // nil = token válido
+	}{
+		{"valid", rs256(nil), nil},
+		{"legacy hmac without kid", signWith(t, jwt.SigningMethodHS256, "", []byte("legacy-secret"), claims(nil)), nil},
+		{"nbf within leeway", rs256(func(c *models.AppClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second)) }), nil},
+		{"expired within leeway", rs256(func(c *models.AppClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) }), nil},
+		{"wrong issuer", rs256(func(c *models.AppClaims) { c.Issuer = "other-env" }), utils.ErrInvalidToken},
+		{"missing issuer", rs256(func(c *models.AppClaims) { c.Issuer = "" }), utils.ErrInvalidToken},
+		{"wrong audience", rs256(func(c *models.AppClaims) { c.Audience = jwt.ClaimStrings{"other-api"} }), utils.ErrInvalidToken},
+		{"missing audience", rs256(func(c *models.AppClaims) { c.Audience = nil }), utils.ErrInvalidToken},
+		{"nbf beyond leeway", rs256(func(c *models.AppClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute)) }), utils.ErrInvalidToken},
+		{"iat beyond leeway", rs256(func(c *models.AppClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(2 * time.Minute)) }), utils.ErrInvalidToken},
+		{"expired beyond leeway", rs256(func(c *models.AppClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }), utils.ErrInvalidToken},
+		{"missing exp", rs256(func(c *models.AppClaims) { c.ExpiresAt = nil }), utils.ErrInvalidToken},
+		{"missing iat", rs256(func(c *models.AppClaims) { c.IssuedAt = nil }), utils.ErrInvalidClaims},
+		{"missing jti", rs256(func(c *models.AppClaims) { c.ID = "" }), utils.ErrInvalidClaims},
+		{"missing kid", signWith(t, jwt.SigningMethodRS256, "", private, claims(nil)), utils.ErrUnsupportedAlg},
+		{"unknown kid", signWith(t, jwt.SigningMethodRS256, "rsa-2", private, claims(nil)), utils.ErrUnknownKey},
+		{"alg confusion with public key pem", signWith(t, jwt.SigningMethodHS256, "rsa-1", publicPEM, claims(nil)), utils.ErrUnsupportedAlg},
+		{"alg confusion with public key der", signWith(t, jwt.SigningMethodHS256, "rsa-1", publicDER, claims(nil)), utils.ErrUnsupportedAlg},
+		{"alg none", signWith(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, claims(nil)), utils.ErrInvalidToken},
+		{"tampered signature", rs256(nil) + "x", utils.ErrInvalidToken},
+		{"malformed", "not-a-jwt", utils.ErrInvalidToken},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := utils.ParseAndValidateToken(context.Background(), tc.token, options)
+			if tc.err == nil {
+				if err != nil || parsed.UserId != 1 {
+					t.Fatalf("ParseAndValidateToken = %+v, %v; want a valid token", parsed, err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("ParseAndValidateToken error = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestParseAndValidateTokenRevoked(t *testing.T) {
+	options, _ := testTokenOptions(t)
+	token, err := options.Keys.Sign(&models.AppClaims{
+		UserId: 1,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-revoked",
+			Issuer:    options.Issuer,
+			Audience:  jwt.ClaimStrings{options.Audience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if _, err := utils.ParseAndValidateToken(context.Background(), token, options); err != nil {
+		t.Fatalf("before revocation: %v", err)
+	}
+
+	if err := repository.RevokeToken(context.Background(), "jti-revoked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := utils.ParseAndValidateToken(context.Background(), token, options); !errors.Is(err, utils.ErrTokenRevoked) {
+		t.Fatalf("after revocation: error = %v, want ErrTokenRevoked", err)
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Reglas de la política de contraseñas
+const (
+	PasswordMinLength = 8
+	PasswordMaxBytes  = 72 // bcrypt ignora (o rechaza) los bytes a partir del 72
+)
+
+// FieldError describe por qué un campo de la request no es válido
+//
+//	{"field": "email", "code": "email", "message": "must be a valid email address"}
+type FieldError struct {
+	Field   string `json:"field"`   // Nombre del campo en el JSON
+	Code    string `json:"code"`    // Regla que falló: required, email, min, max o password
+	Message string `json:"message"` // Mensaje para personas
+}
+
+// Validate aplica las reglas declaradas en la etiqueta validate de los campos string de un struct
+// Las reglas se separan por comas y se evalúan en orden; por campo se informa solo el primer fallo:
+//
+//	Email string `json:"email" validate:"required,email,max=100"`
+//
+// Reglas soportadas:
+//   - required: el valor no puede estar vacío (ni ser solo espacios)
+//   - email: dirección de email válida, sin nombre ("Ana <ana@x.io>" no es válido)
+//   - min=N / max=N: longitud mínima y máxima en caracteres
+//   - password: la política de contraseñas (ver validatePassword)
+//
+// Las reglas distintas de required no se evalúan sobre valores vacíos
+// Retorna nil si todos los campos son válidos
+func Validate(value any) []FieldError {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []FieldError
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		rules, ok := field.Tag.Lookup("validate")
+		if !ok || field.Type.Kind() != reflect.String {
+			continue
+		}
+		if err := validateField(jsonName(field), v.Field(i).String(), rules); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	return errs
+}
+
+// validateField evalúa las reglas de un campo y devuelve el primer fallo
+func validateField(name string, value string, rules string) *FieldError {
+	for _, rule := range strings.Split(rules, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if rule != "required" && value == "" {
+			continue
+		}
+
+		var message string
+		switch rule {
+		case "required":
+			if strings.TrimSpace(value) == "" {
+				message = "is required"
+			}
+		case "email":
+			if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+				message = "must be a valid email address"
+			}
+		case "min":
+			if limit := ruleParam(rule, param); utf8.RuneCountInString(value) < limit {
+				message = fmt.Sprintf("must be at least %d characters long", limit)
+			}
+		case "max":
+			if limit := ruleParam(rule, param); utf8.RuneCountInString(value) > limit {
+				message = fmt.Sprintf("must be at most %d characters long", limit)
+			}
+		case "password":
+			message = validatePassword(value)
+		default:
+			panic("utils.Validate: unknown rule " + rule)
+		}
+
+		if message != "" {
+			return &FieldError{Field: name, Code: rule, Message: message}
+		}
+	}
+	return nil
+}
+
+// validatePassword aplica la política de contraseñas: al menos PasswordMinLength caracteres,
+// como máximo PasswordMaxBytes bytes, y al menos una minúscula, una mayúscula y un dígito
+// Retorna el motivo del rechazo, o "" si la contraseña cumple la política
+func validatePassword(password string) string {
+	if utf8.RuneCountInString(password) < PasswordMinLength {
+		return fmt.Sprintf("must be at least %d characters long", PasswordMinLength)
+	}
+	if len(password) > PasswordMaxBytes {
+		return fmt.Sprintf("must be at most %d bytes long", PasswordMaxBytes)
+	}
+
+	var lower, upper, digit bool
+	for _, char := range password {
+		switch {
+		case unicode.IsLower(char):
+			lower = true
+		case unicode.IsUpper(char):
+			upper = true
+		case unicode.IsDigit(char):
+			digit = true
+		}
+	}
+	if !lower || !upper || !digit {
+		return "must contain a lowercase letter, an uppercase letter and a digit"
+	}
+	return ""
+}
+
+// ruleParam interpreta el parámetro numérico de una regla (ej: max=255)
+// Un parámetro inválido es un error de programación en la etiqueta
+func ruleParam(rule string, param string) int {
+	limit, err := strconv.Atoi(param)
+	if err != nil {
+		panic("utils.Validate: invalid parameter for rule " + rule + ": " + param)
+	}
+	return limit
+}
+
+// jsonName devuelve el nombre del campo en el JSON (el de la etiqueta json o, si no hay, el del struct)
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
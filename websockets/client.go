@@ -1,41 +1,193 @@
-// Package websockets maneja la comunicación en tiempo real entre el servidor y los clientes
-// utilizando WebSockets para permitir comunicación bidireccional.
-package websockets
-
-import "github.com/gorilla/websocket"
-
-// Client representa un cliente conectado al servidor WebSocket.
-// Cada cliente tiene una conexión única y un canal para enviar mensajes.
-type Client struct {
-	hub      *Hub            // Referencia al Hub central que maneja todos los clientes
-	id       string          // Identificador único del cliente (actualmente no se usa)
-	socket   *websocket.Conn // Conexión WebSocket activa con el cliente
-	outbound chan []byte     // Canal para enviar mensajes al cliente de forma asíncrona
-}
-
-// NewClient crea una nueva instancia de Client.
-// Parámetros:
-//   - hub: Referencia al Hub que gestionará este cliente
-//   - socket: Conexión WebSocket establecida con el cliente
-// Retorna un puntero a la nueva instancia de Client
-func NewClient(hub *Hub, socket *websocket.Conn) *Client {
-	return &Client{
-		hub:      hub,
-		socket:   socket,
-		outbound: make(chan []byte), // Crea un canal buffered para mensajes salientes
-	}
-}
-
-// Write es el método principal que maneja el envío de mensajes al cliente.
-// Ejecuta en una goroutine separada y escucha continuamente el canal outbound.
-// Cuando recibe un mensaje, lo envía al cliente a través de la conexión WebSocket.
-// Cuando el canal se cierra (no hay más mensajes), envía un mensaje de cierre.
-func (c *Client) Write() {
-	// Itera sobre todos los mensajes que llegan al canal outbound
-	for message := range c.outbound {
-		// Envía cada mensaje como texto al cliente WebSocket
-		c.socket.WriteMessage(websocket.TextMessage, message)
-	}
-	// Cuando el canal se cierra, notifica al cliente que la conexión terminará
-	c.socket.WriteMessage(websocket.CloseMessage, []byte{})
-}
+// Package websockets maneja la comunicación en tiempo real entre el servidor y los clientes
+// utilizando WebSockets para permitir comunicación bidireccional.
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second    // Tiempo máximo para escribir un mensaje en el socket
+	pongWait       = 60 * time.Second    // Tiempo máximo sin recibir nada del cliente (ni pong) antes de darlo por muerto
+	pingPeriod     = (pongWait * 9) / 10 // Frecuencia de los pings; debe ser menor que pongWait
+	maxMessageSize = 4096                // Tamaño máximo (bytes) de un mensaje enviado por el cliente
+)
+
+// incomingMessage representa un mensaje enviado por el cliente a través del WebSocket.
+// Ejemplos:
+//   - Autenticación: {"type":"auth","token":"<jwt>"}
+//   - Suscripción: {"type":"subscribe","topic":"posts"}
+//   - Cancelar suscripción: {"type":"unsubscribe","topic":"user:42:posts"}
+type incomingMessage struct {
+	Type  string `json:"type"`
+	Token string `json:"token,omitempty"`
+	Topic string `json:"topic,omitempty"`
+}
+
+// replyMessage es la respuesta que recibe el cliente a cada mensaje de control.
+type replyMessage struct {
+	Type   string `json:"type"`
+	UserId int64  `json:"user_id,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Client representa un cliente conectado al servidor WebSocket.
+// Cada cliente tiene una conexión única y un canal para enviar mensajes.
+type Client struct {
+	hub      *Hub            // Referencia al Hub central que maneja todos los clientes
+	id       string          // Identificador único del cliente (actualmente no se usa)
+	userId   int64           // Usuario autenticado dueño de la conexión (0 = anónimo), protegido por hub.mutex
+	socket   *websocket.Conn // Conexión WebSocket activa con el cliente
+	outbound chan []byte     // Cola acotada para enviar mensajes al cliente de forma asíncrona
+	closed   bool            // Indica que outbound ya fue cerrado, protegido por hub.mutex
+
+	closeMessage []byte       // Close frame que Write envía al cerrarse outbound (se asigna antes de cerrarlo)
+	logger       *slog.Logger // Logger con los datos de la conexión (dirección remota y request ID)
+
+	ctx    context.Context    // Contexto de la conexión: se cancela cuando termina Read
+	cancel context.CancelFunc // Cancela ctx
+}
+
+// NewClient crea una nueva instancia de Client.
+// Parámetros:
+//   - ctx: Contexto base de la conexión (se cancela cuando el cliente se desconecta)
+//   - hub: Referencia al Hub que gestionará este cliente
+//   - socket: Conexión WebSocket establecida con el cliente
+//   - userId: Usuario autenticado (0 si la conexión aún es anónima)
+//
+// Retorna un puntero a la nueva instancia de Client
+func NewClient(ctx context.Context, hub *Hub, socket *websocket.Conn, userId int64) *Client {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Client{
+		ctx:      ctx,
+		cancel:   cancel,
+		hub:      hub,
+		userId:   userId,
+		socket:   socket,
+		logger:   slog.With("remote_addr", socket.RemoteAddr().String()),
+		outbound: make(chan []byte, hub.config.SendBufferSize), // Crea un canal buffered (acotado) para mensajes salientes
+	}
+}
+
+// Write es el método principal que maneja el envío de mensajes al cliente.
+// Ejecuta en una goroutine separada y escucha continuamente el canal outbound.
+// Cuando recibe un mensaje, lo envía al cliente a través de la conexión WebSocket.
+// Cada pingPeriod envía un ping para mantener viva la conexión y detectar clientes muertos.
+// Cuando el canal se cierra (no hay más mensajes), envía un mensaje de cierre.
+func (c *Client) Write() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.socket.Close() // Al cerrar el socket, el bucle Read termina y desregistra al cliente
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.outbound:
+			c.socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// Cuando el canal se cierra, notifica al cliente que la conexión terminará
+				c.socket.WriteMessage(websocket.CloseMessage, c.closeMessage)
+				return
+			}
+			// Envía cada mensaje como texto al cliente WebSocket
+			if err := c.socket.WriteMessage(websocket.TextMessage, message); err != nil {
+				c.drain()
+				return
+			}
+		case <-ticker.C:
+			c.socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.socket.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.drain()
+				return
+			}
+		}
+	}
+}
+
+// drain descarta los mensajes pendientes hasta que el Hub cierre el canal outbound,
+// para no retener mensajes de un cliente cuya conexión ya falló.
+func (c *Client) drain() {
+	c.socket.Close()
+	for range c.outbound {
+	}
+}
+
+// UserId devuelve el usuario autenticado asociado al cliente (0 si es anónimo).
+func (c *Client) UserId() int64 {
+	c.hub.mutex.Lock()
+	defer c.hub.mutex.Unlock()
+	return c.userId
+}
+
+// Read es el bucle que procesa los mensajes que envía el cliente.
+// Ejecuta en una goroutine separada hasta que la conexión se cierra o el cliente
+// deja de responder (sin mensajes ni pongs durante pongWait); al terminar, desregistra al cliente.
+// Soporta los mensajes de control auth, subscribe y unsubscribe.
+func (c *Client) Read() {
+	defer func() {
+		// Si el Hub ya se apagó, Run no atiende el canal unregister
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
+		c.socket.Close()
+		c.cancel() // Cancela las validaciones de token en curso de esta conexión
+	}()
+
+	c.socket.SetReadLimit(maxMessageSize)
+	c.socket.SetReadDeadline(time.Now().Add(pongWait))
+	c.socket.SetPongHandler(func(string) error {
+		// Cada pong extiende el plazo de lectura
+		return c.socket.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.socket.ReadMessage()
+		if err != nil {
+			return // Cierre normal, error de red o deadline vencido
+		}
+		c.socket.SetReadDeadline(time.Now().Add(pongWait))
+
+		var message incomingMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.hub.sendToClient(c, replyMessage{Type: "error", Error: "invalid message"})
+			continue
+		}
+		c.handleMessage(message)
+	}
+}
+
+// handleMessage despacha un mensaje de control según su tipo.
+func (c *Client) handleMessage(message incomingMessage) {
+	switch message.Type {
+	case "auth":
+		userId, err := c.hub.authenticate(c.ctx, message.Token)
+		if err == nil {
+			// Una conexión ya autenticada solo admite tokens del mismo usuario (p. ej. al renovarlo)
+			err = c.hub.bindUser(c, userId)
+		}
+		if err != nil {
+			c.hub.sendToClient(c, replyMessage{Type: "auth_error", Error: err.Error()})
+			return
+		}
+		c.hub.sendToClient(c, replyMessage{Type: "auth_ok", UserId: userId})
+	case "subscribe":
+		if err := c.hub.Subscribe(c, message.Topic); err != nil {
+			c.hub.sendToClient(c, replyMessage{Type: "error", Topic: message.Topic, Error: err.Error()})
+			return
+		}
+		c.hub.sendToClient(c, replyMessage{Type: "subscribed", Topic: message.Topic})
+	case "unsubscribe":
+		c.hub.Unsubscribe(c, message.Topic)
+		c.hub.sendToClient(c, replyMessage{Type: "unsubscribed", Topic: message.Topic})
+	default:
+		c.hub.sendToClient(c, replyMessage{Type: "error", Error: "unknown message type: " + message.Type})
+	}
+}
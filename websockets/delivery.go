@@ -0,0 +1,112 @@
+package websockets
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// SlowConsumerPolicy define qué hacer cuando la cola de salida de un cliente está llena.
+type SlowConsumerPolicy string
+
+const (
+	PolicyDropOldest SlowConsumerPolicy = "drop_oldest" // Descarta el mensaje más antiguo de la cola para encolar el nuevo
+	PolicyDropNewest SlowConsumerPolicy = "drop_newest" // Descarta el mensaje nuevo y conserva la cola
+	PolicyDisconnect SlowConsumerPolicy = "disconnect"  // Desconecta al cliente lento
+
+	DefaultSendBufferSize = 256 // Mensajes pendientes por cliente antes de aplicar la política
+)
+
+// ErrInvalidPolicy indica una política de consumidores lentos desconocida.
+var ErrInvalidPolicy = errors.New("invalid slow consumer policy")
+
+// HubConfig contiene los parámetros de entrega de mensajes del Hub.
+// Los valores vacíos se reemplazan por los valores por defecto.
+type HubConfig struct {
+	SendBufferSize     int                // Tamaño de la cola de salida de cada cliente
+	SlowConsumerPolicy SlowConsumerPolicy // Política cuando la cola de un cliente está llena
+}
+
+// withDefaults completa la configuración con los valores por defecto y la valida.
+func (c HubConfig) withDefaults() (HubConfig, error) {
+	if c.SendBufferSize <= 0 {
+		c.SendBufferSize = DefaultSendBufferSize
+	}
+	switch c.SlowConsumerPolicy {
+	case "":
+		c.SlowConsumerPolicy = PolicyDropOldest
+	case PolicyDropOldest, PolicyDropNewest, PolicyDisconnect:
+	default:
+		return c, ErrInvalidPolicy
+	}
+	return c, nil
+}
+
+// HubStats es una foto de las métricas de entrega del Hub.
+type HubStats struct {
+	Clients              int    `json:"clients"`                // Clientes conectados actualmente
+	MessagesSent         uint64 `json:"messages_sent"`          // Mensajes encolados para algún cliente
+	MessagesDropped      uint64 `json:"messages_dropped"`       // Mensajes descartados por colas llenas
+	SlowConsumersEvicted uint64 `json:"slow_consumers_evicted"` // Clientes desconectados por ser lentos
+}
+
+// deliveryStats acumula las métricas de entrega con contadores atómicos.
+type deliveryStats struct {
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	evicted atomic.Uint64
+}
+
+// Stats devuelve las métricas de entrega acumuladas.
+func (h *Hub) Stats() HubStats {
+	h.mutex.Lock()
+	clients := len(h.clients)
+	h.mutex.Unlock()
+
+	return HubStats{
+		Clients:              clients,
+		MessagesSent:         h.stats.sent.Load(),
+		MessagesDropped:      h.stats.dropped.Load(),
+		SlowConsumersEvicted: h.stats.evicted.Load(),
+	}
+}
+
+// enqueue encola un mensaje para un cliente sin bloquear nunca al llamador.
+// Si la cola está llena aplica la política de consumidores lentos configurada.
+// Debe llamarse con h.mutex tomado: así el canal no puede cerrarse durante el envío.
+func (h *Hub) enqueue(client *Client, message []byte) {
+	if client.closed {
+		return
+	}
+
+	select {
+	case client.outbound <- message:
+		h.stats.sent.Add(1)
+		return
+	default:
+	}
+
+	// La cola está llena: el cliente no consume los mensajes a tiempo
+	switch h.config.SlowConsumerPolicy {
+	case PolicyDropNewest:
+		h.stats.dropped.Add(1)
+	case PolicyDropOldest:
+		select {
+		case <-client.outbound:
+			h.stats.dropped.Add(1)
+		default:
+		}
+		select {
+		case client.outbound <- message:
+			h.stats.sent.Add(1)
+		default:
+			h.stats.dropped.Add(1)
+		}
+	case PolicyDisconnect:
+		h.stats.dropped.Add(1)
+		h.stats.evicted.Add(1)
+		client.logger.Warn("slow websocket client disconnected", "user_id", client.userId)
+		// Al cerrar outbound, Write envía el mensaje de cierre y cierra el socket;
+		// luego Read termina y desregistra al cliente del Hub
+		h.closeClient(client, nil)
+	}
+}
@@ -0,0 +1,87 @@
+package websockets
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// queued devuelve los mensajes pendientes en la cola del cliente y si la cola está cerrada
+func queued(client *Client) ([]string, bool) {
+	var messages []string
+	for {
+		select {
+		case data, ok := <-client.outbound:
+			if !ok {
+				return messages, true
+			}
+			messages = append(messages, string(data))
+		default:
+			return messages, false
+		}
+	}
+}
+
+func TestSlowConsumerPolicies(t *testing.T) {
+	cases := []struct {
+		policy SlowConsumerPolicy
+		queue  []string // Cola del cliente lento tras enviar m1..m4 con capacidad 2
+		closed bool
+		stats  HubStats // MessagesSent: encolados al cliente lento + los 4 del rápido
+	}{
+		{PolicyDropOldest, []string{`"m3"`, `"m4"`}, false, HubStats{Clients: 2, MessagesSent: 4 + 4, MessagesDropped: 2}},
+		{PolicyDropNewest, []string{`"m1"`, `"m2"`}, false, HubStats{Clients: 2, MessagesSent: 2 + 4, MessagesDropped: 2}},
+		// El cliente desconectado no cuenta los mensajes posteriores al cierre como descartados
+		{PolicyDisconnect, []string{`"m1"`, `"m2"`}, true, HubStats{Clients: 2, MessagesSent: 2 + 4, MessagesDropped: 1, SlowConsumersEvicted: 1}},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			hub := newTestHub(t, HubConfig{SendBufferSize: 2, SlowConsumerPolicy: tc.policy})
+			slow := newTestClient(hub, 1)
+			fast := newTestClient(hub, 2)
+
+			// El cliente rápido vacía su cola tras cada mensaje; el lento nunca lee
+			for _, message := range []string{"m1", "m2", "m3", "m4"} {
+				hub.SendMessageToClients(message, nil)
+				if got, _ := queued(fast); !slices.Equal(got, []string{`"` + message + `"`}) {
+					t.Fatalf("fast client got %v after %s", got, message)
+				}
+			}
+
+			got, closed := queued(slow)
+			if !slices.Equal(got, tc.queue) || closed != tc.closed {
+				t.Errorf("slow client queue = %v (closed %v), want %v (closed %v)", got, closed, tc.queue, tc.closed)
+			}
+			// Los clientes solo salen de la lista al desregistrarse (cuando termina Read)
+			if stats := hub.Stats(); stats != tc.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tc.stats)
+			}
+		})
+	}
+}
+
+func TestSendToClosedClient(t *testing.T) {
+	hub := newTestHub(t, HubConfig{SendBufferSize: 1, SlowConsumerPolicy: PolicyDisconnect})
+	client := newTestClient(hub, 1)
+	hub.onDisconnect(client)
+
+	// Tras desregistrarse, enviar al cliente no hace nada (ni entra en pánico por el canal cerrado)
+	hub.sendToClient(client, "late")
+	hub.SendMessageToUser(1, "late")
+	if got, closed := queued(client); len(got) != 0 || !closed {
+		t.Fatalf("queue after disconnect = %v (closed %v), want empty and closed", got, closed)
+	}
+	if stats := hub.Stats(); stats != (HubStats{}) {
+		t.Fatalf("stats = %+v, want zero", stats)
+	}
+}
+
+func TestHubConfigDefaults(t *testing.T) {
+	config, err := HubConfig{}.withDefaults()
+	if err != nil || config.SendBufferSize != DefaultSendBufferSize || config.SlowConsumerPolicy != PolicyDropOldest {
+		t.Fatalf("defaults = %+v (%v)", config, err)
+	}
+	if _, err := NewHub(HubConfig{SlowConsumerPolicy: "block"}); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("NewHub with an unknown policy = %v, want ErrInvalidPolicy", err)
+	}
+}
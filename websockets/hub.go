@@ -1,125 +1,356 @@
-// Package websockets gestiona las conexiones WebSocket múltiples y la comunicación
-// entre el servidor y todos los clientes conectados.
-package websockets
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"sync"
-
-	"github.com/gorilla/websocket"
-)
-
-// upgrader es una instancia global que permite convertir conexiones HTTP regulares
-// en conexiones WebSocket. CheckOrigin permite conexiones desde cualquier origen
-// (en producción deberías validar los orígenes permitidos por seguridad).
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Permitir conexiones desde cualquier origen
-	},
-}
-
-// Hub es el centro de comunicaciones que gestiona todos los clientes WebSocket conectados.
-// Coordina el registro, desregistro y la comunicación entre clientes.
-type Hub struct {
-	clients    []*Client    // Lista de todos los clientes actualmente conectados
-	register   chan *Client // Canal para registrar nuevos clientes que se conectan
-	unregister chan *Client // Canal para desregistrar clientes que se desconectan
-	mutex      *sync.Mutex  // Mutex para proteger el acceso concurrente a la lista de clientes
-}
-
-// NewHub crea una nueva instancia de Hub.
-// Inicializa todos los campos necesarios:
-// - Un slice vacío para clientes
-// - Canales para registro y desregistro de clientes
-// - Un mutex para proteger el acceso concurrente
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make([]*Client, 0), // Crea un slice vacío de clientes
-		register:   make(chan *Client), // Canal para registrar nuevos clientes
-		unregister: make(chan *Client), // Canal para desregistrar clientes
-		mutex:      &sync.Mutex{},      // Mutex para protección de concurrencia
-	}
-}
-
-// WebSocketHandler maneja nuevas conexiones WebSocket entrantes.
-// Pasos que realiza:
-// 1. Convierte la conexión HTTP a WebSocket usando el upgrader
-// 2. Crea un nuevo cliente para esa conexión
-// 3. Registra el cliente en el Hub
-// 4. Inicia una goroutine para manejar los mensajes del cliente
-func (h *Hub) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	// Intenta convertir la conexión HTTP a WebSocket
-	socket, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		http.Error(w, "Could not upgrade websocket connection", http.StatusInternalServerError)
-		return
-	}
-
-	// Crea un nuevo cliente con la conexión WebSocket
-	client := NewClient(h, socket)
-	// Envía el cliente al canal de registro para que el Hub lo añada a la lista
-	h.register <- client
-
-	// Inicia una goroutine para manejar el envío de mensajes a este cliente
-	go client.Write()
-}
-
-// Run es el bucle principal del Hub que maneja el registro y desregistro de clientes.
-// Utiliza un select statement para escuchar en ambos canales de forma no bloqueante.
-// Este método debe ejecutarse en una goroutine separada ya que es un bucle infinito.
-func (h *Hub) Run() {
-	log.Println("🌀 WebSocket Hub is running...")
-	for {
-		select {
-		// Cuando llega un nuevo cliente para registrar
-		case client := <-h.register:
-			log.Println("➕ Nuevo cliente registrado:", client.socket.RemoteAddr())
-			h.onConnect(client) // Llama al método para manejar la conexión del cliente
-		// Cuando llega un cliente para desregistrar
-		case client := <-h.unregister:
-			log.Println("💥 Cliente desregistrado:", client.socket.RemoteAddr())
-			h.onDisconnect(client) // Llama al método para manejar la desconexión del cliente
-		}
-	}
-}
-
-// onConnect maneja la lógica cuando un nuevo cliente se conecta al Hub.
-// Registra el cliente en la lista de clientes conectados y puede realizar otras
-// acciones como enviar un mensaje de bienvenida.
-func (h *Hub) onConnect(client *Client) {
-	h.mutex.Lock()         // Bloquea para acceso exclusivo
-	defer h.mutex.Unlock() // Asegura que se desbloquee al final
-
-	// Añade el nuevo cliente a la lista
-	client.id = client.socket.RemoteAddr().String()
-	h.clients = append(h.clients, client)
-}
-
-// onDisconnect maneja la lógica cuando un cliente se desconecta del Hub.
-// Elimina el cliente de la lista de clientes conectados y puede realizar otras
-// acciones como notificar a otros clientes.
-func (h *Hub) onDisconnect(client *Client) {
-	h.mutex.Lock()         // Bloquea para acceso exclusivo
-	defer h.mutex.Unlock() // Asegura que se desbloquee al final
-
-	// Busca el cliente en la lista y lo elimina
-	for i, c := range h.clients {
-		if c == client {
-			// Elimina el cliente usando slicing de Go
-			h.clients = append(h.clients[:i], h.clients[i+1:]...)
-			break
-		}
-	}
-}
-
-func (h *Hub) SendMessageToClients(message any, ignore *Client) {
-	jsonMessage, _ := json.Marshal(message)
-
-	for _, client := range h.clients {
-		if client != ignore { // No enviar al cliente que envió el mensaje
-			client.outbound <- jsonMessage // Enviar el mensaje al canal outbound del cliente
-		}
-	}
-}
+// Package websockets gestiona las conexiones WebSocket múltiples y la comunicación
+// entre el servidor y todos los clientes conectados.
+package websockets
+
+import (
+	"afperdomo2/go/rest-ws/logging"
+	"afperdomo2/go/rest-ws/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// upgrader es una instancia global que permite convertir conexiones HTTP regulares
+// en conexiones WebSocket. CheckOrigin permite conexiones desde cualquier origen
+// (en producción deberías validar los orígenes permitidos por seguridad).
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true // Permitir conexiones desde cualquier origen
+	},
+}
+
+// closeGoingAway es el close frame que reciben los clientes cuando el servidor se apaga.
+var closeGoingAway = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+var (
+	// ErrAuthUnavailable indica que el Hub no tiene configurado un autenticador de tokens.
+	ErrAuthUnavailable = errors.New("websocket authentication is not configured")
+	// ErrUserMismatch indica que se intentó autenticar como otro usuario una conexión ya autenticada.
+	ErrUserMismatch = errors.New("connection is already authenticated as another user")
+)
+
+// Authenticator valida un token (el mismo JWT de la API REST) y devuelve el ID del usuario.
+type Authenticator func(ctx context.Context, token string) (int64, error)
+
+// Hub es el centro de comunicaciones que gestiona todos los clientes WebSocket conectados.
+// Coordina el registro, desregistro y la comunicación entre clientes.
+type Hub struct {
+	clients    []*Client                   // Lista de todos los clientes actualmente conectados
+	register   chan *Client                // Canal para registrar nuevos clientes que se conectan
+	unregister chan *Client                // Canal para desregistrar clientes que se desconectan
+	topics     map[string]map[*Client]bool // Índice de suscriptores por topic
+	mutex      *sync.Mutex                 // Mutex para proteger el acceso concurrente a clientes y topics
+
+	config        HubConfig     // Parámetros de entrega (tamaño de colas y política de consumidores lentos)
+	stats         deliveryStats // Métricas de entrega de mensajes
+	authenticator Authenticator // Valida los tokens de las conexiones autenticadas
+
+	done    chan struct{}  // Se cierra al apagar el Hub para detener Run
+	running bool           // Indica que el bucle Run está activo, protegido por mutex
+	stopped bool           // Indica que el Hub se está apagando, protegido por mutex
+	writers sync.WaitGroup // Goroutines Write activas (para esperar los close frames al apagar)
+}
+
+// NewHub crea una nueva instancia de Hub.
+// Inicializa todos los campos necesarios:
+// - Un slice vacío para clientes
+// - Canales para registro y desregistro de clientes
+// - Un índice vacío de suscriptores por topic
+// - Un mutex para proteger el acceso concurrente
+//
+// Retorna un error si la configuración de entrega es inválida
+func NewHub(config HubConfig) (*Hub, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return &Hub{
+		clients:    make([]*Client, 0),                // Crea un slice vacío de clientes
+		register:   make(chan *Client),                // Canal para registrar nuevos clientes
+		unregister: make(chan *Client),                // Canal para desregistrar clientes
+		topics:     make(map[string]map[*Client]bool), // Índice vacío de suscriptores por topic
+		mutex:      &sync.Mutex{},                     // Mutex para protección de concurrencia
+		config:     config,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// SetAuthenticator configura la función usada para validar los tokens de los clientes.
+func (h *Hub) SetAuthenticator(authenticator Authenticator) {
+	h.authenticator = authenticator
+}
+
+// authenticate valida un token con el autenticador configurado.
+func (h *Hub) authenticate(ctx context.Context, token string) (int64, error) {
+	if h.authenticator == nil {
+		return 0, ErrAuthUnavailable
+	}
+	return h.authenticator(ctx, token)
+}
+
+// WebSocketHandler maneja nuevas conexiones WebSocket entrantes.
+// Pasos que realiza:
+// 1. Si la request trae un token (header, ?token=<jwt> o cookie), lo valida antes del upgrade (401 si es inválido)
+// 2. Convierte la conexión HTTP a WebSocket usando el upgrader
+// 3. Crea un nuevo cliente para esa conexión, asociado al usuario autenticado
+// 4. Registra el cliente en el Hub (si el Hub se está apagando, cierra la conexión con 1001)
+// 5. Inicia una goroutine para manejar los mensajes del cliente
+//
+// Sin token, el cliente puede autenticarse con su primer mensaje:
+// {"type":"auth","token":"<jwt>"}
+func (h *Hub) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	var userId int64
+	token, err := utils.ExtractWebSocketToken(r)
+	if err != nil && !errors.Is(err, utils.ErrMissingAuthHeader) {
+		utils.WriteUnauthorized(w, err)
+		return
+	}
+	if token != "" {
+		id, err := h.authenticate(r.Context(), token)
+		if err != nil {
+			utils.WriteUnauthorized(w, err)
+			return
+		}
+		userId = id
+	}
+
+	// Intenta convertir la conexión HTTP a WebSocket
+	socket, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		http.Error(w, "Could not upgrade websocket connection", http.StatusInternalServerError)
+		return
+	}
+
+	// Crea un nuevo cliente con la conexión WebSocket
+	// Su contexto conserva los valores de la request del upgrade pero no su cancelación,
+	// que ocurre en cuanto este handler retorna; sus líneas de log llevan el request ID
+	client := NewClient(context.WithoutCancel(r.Context()), h, socket, userId)
+	client.logger = client.logger.With("request_id", logging.RequestIDFromContext(r.Context()))
+
+	// Mientras el Hub se apaga no se aceptan clientes nuevos
+	h.mutex.Lock()
+	if h.stopped {
+		h.mutex.Unlock()
+		socket.WriteControl(websocket.CloseMessage, closeGoingAway, time.Now().Add(writeWait))
+		socket.Close()
+		return
+	}
+	h.writers.Add(1)
+	h.mutex.Unlock()
+
+	// Inicia una goroutine para manejar el envío de mensajes a este cliente
+	go func() {
+		defer h.writers.Done()
+		client.Write()
+	}()
+
+	// Envía el cliente al canal de registro para que el Hub lo añada a la lista
+	select {
+	case h.register <- client:
+	case <-h.done:
+		// El Hub se apagó antes de registrar al cliente: Write envía el close frame y termina
+		h.mutex.Lock()
+		h.closeClient(client, closeGoingAway)
+		h.mutex.Unlock()
+	}
+
+	// Inicia una goroutine para procesar los mensajes de control del cliente
+	// (autenticación por mensaje y suscripción a topics)
+	go client.Read()
+}
+
+// Run es el bucle principal del Hub que maneja el registro y desregistro de clientes.
+// Utiliza un select statement para escuchar en ambos canales de forma no bloqueante.
+// Este método debe ejecutarse en una goroutine separada; termina cuando se llama a Shutdown.
+func (h *Hub) Run() {
+	h.setRunning(true)
+	defer h.setRunning(false)
+
+	slog.Info("websocket hub running")
+	for {
+		select {
+		// Cuando el Hub se apaga
+		case <-h.done:
+			slog.Info("websocket hub stopped")
+			return
+		// Cuando llega un nuevo cliente para registrar
+		case client := <-h.register:
+			client.logger.Info("websocket client registered", "user_id", client.UserId())
+			h.onConnect(client) // Llama al método para manejar la conexión del cliente
+		// Cuando llega un cliente para desregistrar
+		case client := <-h.unregister:
+			client.logger.Info("websocket client unregistered", "user_id", client.UserId())
+			h.onDisconnect(client) // Llama al método para manejar la desconexión del cliente
+		}
+	}
+}
+
+// setRunning marca si el bucle Run está activo.
+func (h *Hub) setRunning(running bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.running = running
+}
+
+// Running indica si el Hub está atendiendo clientes: Run está activo y no se ha llamado a Shutdown.
+func (h *Hub) Running() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.running && !h.stopped
+}
+
+// onConnect maneja la lógica cuando un nuevo cliente se conecta al Hub.
+// Registra el cliente en la lista de clientes conectados y puede realizar otras
+// acciones como enviar un mensaje de bienvenida.
+func (h *Hub) onConnect(client *Client) {
+	h.mutex.Lock()         // Bloquea para acceso exclusivo
+	defer h.mutex.Unlock() // Asegura que se desbloquee al final
+
+	// Añade el nuevo cliente a la lista
+	client.id = client.socket.RemoteAddr().String()
+	h.clients = append(h.clients, client)
+
+	// Si el Hub empezó a apagarse mientras el cliente se registraba, se cierra de inmediato
+	if h.stopped {
+		h.closeClient(client, closeGoingAway)
+	}
+}
+
+// onDisconnect maneja la lógica cuando un cliente se desconecta del Hub.
+// Elimina el cliente de la lista de clientes conectados y puede realizar otras
+// acciones como notificar a otros clientes.
+func (h *Hub) onDisconnect(client *Client) {
+	h.mutex.Lock()         // Bloquea para acceso exclusivo
+	defer h.mutex.Unlock() // Asegura que se desbloquee al final
+
+	// Busca el cliente en la lista y lo elimina
+	for i, c := range h.clients {
+		if c == client {
+			// Elimina el cliente usando slicing de Go
+			h.clients = append(h.clients[:i], h.clients[i+1:]...)
+			break
+		}
+	}
+
+	// Cierra el canal de salida (una sola vez) para que termine la goroutine Write
+	h.closeClient(client, nil)
+
+	// Elimina las suscripciones del cliente
+	for topic, subscribers := range h.topics {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// closeClient cierra el canal de salida del cliente (una sola vez), de modo que Write envíe
+// el close frame indicado (vacío si es nil) y termine. Debe llamarse con el mutex tomado.
+func (h *Hub) closeClient(client *Client, closeMessage []byte) {
+	if client.closed {
+		return
+	}
+	client.closeMessage = closeMessage
+	client.closed = true
+	close(client.outbound)
+}
+
+// Shutdown apaga el Hub ordenadamente:
+// 1. Deja de aceptar clientes nuevos y detiene el bucle Run
+// 2. Envía a cada cliente un close frame 1001 (Going Away)
+// 3. Espera a que todas las goroutines Write terminen o a que venza ctx
+//
+// Llamarlo más de una vez no tiene efecto.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mutex.Lock()
+	if h.stopped {
+		h.mutex.Unlock()
+		return nil
+	}
+	h.stopped = true
+	close(h.done)
+	for _, client := range h.clients {
+		h.closeClient(client, closeGoingAway)
+	}
+	h.mutex.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SendMessageToClients envía un mensaje a todos los clientes conectados, excepto a ignore.
+// Nunca bloquea: los clientes lentos se manejan según la política configurada.
+func (h *Hub) SendMessageToClients(message any, ignore *Client) {
+	jsonMessage, _ := json.Marshal(message)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, client := range h.clients {
+		if client != ignore { // No enviar al cliente que envió el mensaje
+			h.enqueue(client, jsonMessage) // Encolar el mensaje en el canal outbound del cliente
+		}
+	}
+}
+
+// bindUser asocia un cliente ya conectado al usuario autenticado.
+// Una vez asociado, el cliente no puede pasar a ser de otro usuario.
+func (h *Hub) bindUser(client *Client, userId int64) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if client.userId != 0 && client.userId != userId {
+		return ErrUserMismatch
+	}
+	client.userId = userId
+	return nil
+}
+
+// sendToClient envía un mensaje a un único cliente, si sigue conectado.
+func (h *Hub) sendToClient(client *Client, message any) {
+	jsonMessage, _ := json.Marshal(message)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.enqueue(client, jsonMessage)
+}
+
+// SendMessageToUser envía un mensaje a todas las conexiones abiertas de un usuario.
+func (h *Hub) SendMessageToUser(userId int64, message any) {
+	h.SendMessageToUsers([]int64{userId}, message)
+}
+
+// SendMessageToUsers envía un mensaje a todas las conexiones de un conjunto de usuarios.
+// Los clientes anónimos nunca reciben mensajes dirigidos.
+func (h *Hub) SendMessageToUsers(userIds []int64, message any) {
+	jsonMessage, _ := json.Marshal(message)
+
+	targets := make(map[int64]bool, len(userIds))
+	for _, id := range userIds {
+		if id != 0 {
+			targets[id] = true
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, client := range h.clients {
+		if targets[client.userId] {
+			h.enqueue(client, jsonMessage)
+		}
+	}
+}
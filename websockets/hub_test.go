@@ -0,0 +1,104 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// newTestHub crea un Hub sin iniciar Run; los tests registran los clientes directamente
+func newTestHub(t *testing.T, config HubConfig) *Hub {
+	t.Helper()
+	hub, err := NewHub(config)
+	if err != nil {
+		t.Fatalf("NewHub: %v", err)
+	}
+	return hub
+}
+
+// newTestClient crea un cliente registrado en el Hub sin socket: sus mensajes se leen de outbound
+func newTestClient(hub *Hub, userId int64) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
+		hub:      hub,
+		userId:   userId,
+		outbound: make(chan []byte, hub.config.SendBufferSize),
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	hub.mutex.Lock()
+	hub.clients = append(hub.clients, client)
+	hub.mutex.Unlock()
+	return client
+}
+
+// reply lee la siguiente respuesta de control encolada para el cliente
+func reply(t *testing.T, client *Client) replyMessage {
+	t.Helper()
+	select {
+	case data := <-client.outbound:
+		var message replyMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("invalid reply %s: %v", data, err)
+		}
+		return message
+	default:
+		t.Fatal("no reply queued")
+		return replyMessage{}
+	}
+}
+
+func TestClientReauthentication(t *testing.T) {
+	hub := newTestHub(t, HubConfig{})
+	hub.SetAuthenticator(func(ctx context.Context, token string) (int64, error) {
+		switch token {
+		case "ana", "ana-renewed":
+			return 1, nil
+		case "bob":
+			return 2, nil
+		}
+		return 0, errors.New("invalid token")
+	})
+	client := newTestClient(hub, 0)
+
+	steps := []struct {
+		token  string
+		reply  string
+		userId int64
+	}{
+		{"nope", "auth_error", 0},
+		{"ana", "auth_ok", 1},
+		{"ana-renewed", "auth_ok", 1}, // Renovar el token del mismo usuario está permitido
+		{"bob", "auth_error", 1},      // Cambiar de usuario no
+		{"nope", "auth_error", 1},
+	}
+	for _, step := range steps {
+		client.handleMessage(incomingMessage{Type: "auth", Token: step.token})
+		if got := reply(t, client); got.Type != step.reply {
+			t.Fatalf("auth with %q: reply %+v, want %q", step.token, got, step.reply)
+		}
+		if got := client.UserId(); got != step.userId {
+			t.Fatalf("after auth with %q: user id = %d, want %d", step.token, got, step.userId)
+		}
+	}
+}
+
+func TestClientAuthenticationUsesConnectionContext(t *testing.T) {
+	hub := newTestHub(t, HubConfig{})
+	var authCtx context.Context
+	hub.SetAuthenticator(func(ctx context.Context, token string) (int64, error) {
+		authCtx = ctx
+		return 1, nil
+	})
+	client := newTestClient(hub, 0)
+
+	client.handleMessage(incomingMessage{Type: "auth", Token: "ana"})
+	client.cancel() // Lo que hace Read al terminar
+	if authCtx == nil || authCtx.Err() == nil {
+		t.Fatal("authenticator context is not cancelled when the connection ends")
+	}
+}
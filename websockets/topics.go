@@ -0,0 +1,82 @@
+package websockets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// TopicPosts es el topic donde se publican los eventos de todos los posts.
+const TopicPosts = "posts"
+
+// ErrInvalidTopic indica que el cliente intentó suscribirse a un topic desconocido.
+var ErrInvalidTopic = errors.New("invalid topic")
+
+// userPostsTopicPattern valida los topics por autor, por ejemplo "user:42:posts".
+var userPostsTopicPattern = regexp.MustCompile(`^user:[1-9][0-9]*:posts$`)
+
+// UserPostsTopic devuelve el topic con los eventos de los posts de un usuario.
+func UserPostsTopic(userId int64) string {
+	return fmt.Sprintf("user:%d:posts", userId)
+}
+
+// isValidTopic indica si un topic es uno de los soportados por el servidor.
+func isValidTopic(topic string) bool {
+	return topic == TopicPosts || userPostsTopicPattern.MatchString(topic)
+}
+
+// Subscribe añade el cliente a los suscriptores de un topic.
+func (h *Hub) Subscribe(client *Client, topic string) error {
+	if !isValidTopic(topic) {
+		return ErrInvalidTopic
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subscribers, ok := h.topics[topic]
+	if !ok {
+		subscribers = make(map[*Client]bool)
+		h.topics[topic] = subscribers
+	}
+	subscribers[client] = true
+	return nil
+}
+
+// Unsubscribe elimina el cliente de los suscriptores de un topic.
+func (h *Hub) Unsubscribe(client *Client, topic string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if subscribers, ok := h.topics[topic]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// PublishToTopic envía un mensaje a todos los clientes suscritos a un topic.
+func (h *Hub) PublishToTopic(topic string, message any) {
+	h.PublishToTopics(message, topic)
+}
+
+// PublishToTopics envía un mensaje a los suscriptores de varios topics.
+// Un cliente suscrito a más de uno de los topics recibe el mensaje una sola vez.
+func (h *Hub) PublishToTopics(message any, topics ...string) {
+	jsonMessage, _ := json.Marshal(message)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delivered := make(map[*Client]bool)
+	for _, topic := range topics {
+		for client := range h.topics[topic] {
+			if !delivered[client] {
+				delivered[client] = true
+				h.enqueue(client, jsonMessage)
+			}
+		}
+	}
+}
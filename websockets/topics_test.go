@@ -0,0 +1,91 @@
+package websockets
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSubscribeValidatesTopic(t *testing.T) {
+	hub := newTestHub(t, HubConfig{})
+	client := newTestClient(hub, 1)
+
+	for _, topic := range []string{TopicPosts, UserPostsTopic(42), "user:7:posts"} {
+		if err := hub.Subscribe(client, topic); err != nil {
+			t.Errorf("Subscribe(%q) = %v", topic, err)
+		}
+	}
+	for _, topic := range []string{"", "users", "user:0:posts", "user:-1:posts", "user:abc:posts", "user:42:posts:extra"} {
+		if err := hub.Subscribe(client, topic); !errors.Is(err, ErrInvalidTopic) {
+			t.Errorf("Subscribe(%q) = %v, want ErrInvalidTopic", topic, err)
+		}
+	}
+}
+
+func TestPublishToTopics(t *testing.T) {
+	hub := newTestHub(t, HubConfig{})
+	both := newTestClient(hub, 1)    // Suscrito a los dos topics del evento
+	author := newTestClient(hub, 2)  // Solo al topic del autor
+	other := newTestClient(hub, 3)   // A los posts de otro autor
+	nothing := newTestClient(hub, 4) // Sin suscripciones
+	hub.Subscribe(both, TopicPosts)
+	hub.Subscribe(both, UserPostsTopic(42))
+	hub.Subscribe(author, UserPostsTopic(42))
+	hub.Subscribe(other, UserPostsTopic(7))
+
+	hub.PublishToTopics("event", TopicPosts, UserPostsTopic(42))
+
+	cases := []struct {
+		name   string
+		client *Client
+		want   []string
+	}{
+		{"subscribed to both topics", both, []string{`"event"`}}, // Una sola vez
+		{"subscribed to the author topic", author, []string{`"event"`}},
+		{"subscribed to another author", other, nil},
+		{"not subscribed", nothing, nil},
+	}
+	for _, tc := range cases {
+		if got, _ := queued(tc.client); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Tras cancelar una suscripción el cliente sigue recibiendo por la otra
+	hub.Unsubscribe(both, TopicPosts)
+	hub.PublishToTopic(TopicPosts, "posts-only")
+	hub.PublishToTopic(UserPostsTopic(42), "author-only")
+	if got, _ := queued(both); !slices.Equal(got, []string{`"author-only"`}) {
+		t.Errorf("after unsubscribe: got %v, want only the author event", got)
+	}
+}
+
+func TestDisconnectRemovesSubscriptions(t *testing.T) {
+	hub := newTestHub(t, HubConfig{})
+	leaving := newTestClient(hub, 1)
+	staying := newTestClient(hub, 2)
+	hub.Subscribe(leaving, TopicPosts)
+	hub.Subscribe(leaving, UserPostsTopic(42))
+	hub.Subscribe(leaving, UserPostsTopic(7))
+	hub.Subscribe(staying, TopicPosts)
+
+	hub.onDisconnect(leaving)
+
+	for topic, subscribers := range hub.topics {
+		if subscribers[leaving] {
+			t.Errorf("disconnected client still subscribed to %q", topic)
+		}
+	}
+	// Los topics que se quedan sin suscriptores se eliminan del índice
+	if len(hub.topics) != 1 || !hub.topics[TopicPosts][staying] {
+		t.Errorf("topics after disconnect = %v, want only %q with the remaining client", hub.topics, TopicPosts)
+	}
+	if slices.Contains(hub.clients, leaving) {
+		t.Error("disconnected client still in the client list")
+	}
+
+	hub.PublishToTopics("event", TopicPosts, UserPostsTopic(42))
+	if got, _ := queued(staying); !slices.Equal(got, []string{`"event"`}) {
+		t.Errorf("remaining client got %v", got)
+	}
+}